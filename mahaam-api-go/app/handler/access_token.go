@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"mahaam-api/app/models"
+	"mahaam-api/app/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessTokenHandler interface {
+	Create(c *gin.Context)
+	GetMany(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type accessTokenHandler struct {
+	accessTokenService service.AccessTokenService
+}
+
+func NewAccessTokenHandler(accessTokenService service.AccessTokenService) AccessTokenHandler {
+	return &accessTokenHandler{accessTokenService: accessTokenService}
+}
+
+func RegisterAccessTokenHandler(router *gin.RouterGroup, h AccessTokenHandler) {
+	rg := router.Group("/users/tokens")
+	rg.POST("", h.Create)
+	rg.GET("", h.GetMany)
+	rg.DELETE("/:tokenId", h.Delete)
+}
+
+// Create accepts scopes as repeated or comma separated form values, the token is returned only here
+func (h *accessTokenHandler) Create(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	name := parseFormParam(c, "name")
+
+	var scopes []string
+	for _, value := range c.PostFormArray("scopes") {
+		for _, scope := range strings.Split(value, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	expiresInDays := 0
+	if value := strings.TrimSpace(c.PostForm("expiresInDays")); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			panic(models.InputError("expiresInDays is not valid integer"))
+		}
+		expiresInDays = days
+	}
+
+	c.JSON(http.StatusCreated, h.accessTokenService.Create(meta.UserID, name, scopes, expiresInDays))
+}
+
+func (h *accessTokenHandler) GetMany(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.accessTokenService.GetMany(meta.UserID))
+}
+
+func (h *accessTokenHandler) Delete(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	tokenId := parsePathUuid(c, "tokenId")
+	h.accessTokenService.Delete(meta.UserID, tokenId)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CalendarFeedHandler interface {
+	Get(c *gin.Context)
+	Rotate(c *gin.Context)
+	Revoke(c *gin.Context)
+	Feed(c *gin.Context)
+}
+
+type calendarFeedHandler struct {
+	calendarFeedService service.CalendarFeedService
+}
+
+func NewCalendarFeedHandler(calendarFeedService service.CalendarFeedService) CalendarFeedHandler {
+	return &calendarFeedHandler{calendarFeedService: calendarFeedService}
+}
+
+func RegisterCalendarFeedHandler(router *gin.RouterGroup, h CalendarFeedHandler) {
+	rg := router.Group("/users/calendar-feed")
+	rg.GET("", h.Get)
+	rg.POST("", h.Rotate)
+	rg.DELETE("", h.Revoke)
+
+	// bypasses jwt auth, the token in the url is the only credential
+	router.GET("/calendar/:token", h.Feed)
+}
+
+func (h *calendarFeedHandler) Get(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.calendarFeedService.Get(meta.UserID))
+}
+
+// Rotate creates the feed url or replaces it, the token is returned only here
+func (h *calendarFeedHandler) Rotate(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusCreated, h.calendarFeedService.Rotate(meta.UserID))
+}
+
+func (h *calendarFeedHandler) Revoke(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	h.calendarFeedService.Revoke(meta.UserID)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *calendarFeedHandler) Feed(c *gin.Context) {
+	feedToken := strings.TrimSuffix(parsePathParam(c, "token"), ".ics")
+	c.Header("Cache-Control", "private, max-age=900")
+	w := &attachmentWriter{c: c, contentType: "text/calendar; charset=utf-8"}
+	h.calendarFeedService.Write(feedToken, w)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactHandler interface {
+	GetMany(c *gin.Context)
+	Delete(c *gin.Context)
+	GetGroups(c *gin.Context)
+	CreateGroup(c *gin.Context)
+	RenameGroup(c *gin.Context)
+	DeleteGroup(c *gin.Context)
+	AddToGroup(c *gin.Context)
+	RemoveFromGroup(c *gin.Context)
+	GetBlocked(c *gin.Context)
+	Block(c *gin.Context)
+	Unblock(c *gin.Context)
+}
+
+type contactHandler struct {
+	contactService service.ContactService
+}
+
+func NewContactHandler(contactService service.ContactService) ContactHandler {
+	return &contactHandler{contactService: contactService}
+}
+
+func RegisterContactHandler(router *gin.RouterGroup, h ContactHandler) {
+	contactRouter := router.Group("/contacts")
+
+	contactRouter.GET("", h.GetMany)
+	contactRouter.DELETE("/:contactId", h.Delete)
+	contactRouter.GET("/groups", h.GetGroups)
+	contactRouter.POST("/groups", h.CreateGroup)
+	contactRouter.PATCH("/groups/:groupId/name", h.RenameGroup)
+	contactRouter.DELETE("/groups/:groupId", h.DeleteGroup)
+	contactRouter.POST("/groups/:groupId/contacts", h.AddToGroup)
+	contactRouter.DELETE("/groups/:groupId/contacts/:contactId", h.RemoveFromGroup)
+	contactRouter.GET("/blocked", h.GetBlocked)
+	contactRouter.POST("/blocked", h.Block)
+	contactRouter.DELETE("/blocked/:userId", h.Unblock)
+}
+
+func (h *contactHandler) GetMany(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.contactService.GetMany(meta.UserID))
+}
+
+func (h *contactHandler) Delete(c *gin.Context) {
+	id := parsePathUuid(c, "contactId")
+	meta := parseRequestMeta(c)
+	h.contactService.Delete(meta.UserID, id)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *contactHandler) GetGroups(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.contactService.GetGroups(meta.UserID))
+}
+
+func (h *contactHandler) CreateGroup(c *gin.Context) {
+	name := parseFormParam(c, "name")
+	meta := parseRequestMeta(c)
+	id := h.contactService.CreateGroup(meta.UserID, name)
+	c.JSON(http.StatusCreated, id)
+}
+
+func (h *contactHandler) RenameGroup(c *gin.Context) {
+	id := parsePathUuid(c, "groupId")
+	name := parseFormParam(c, "name")
+	meta := parseRequestMeta(c)
+	h.contactService.RenameGroup(meta.UserID, id, name)
+	c.Status(http.StatusOK)
+}
+
+func (h *contactHandler) DeleteGroup(c *gin.Context) {
+	id := parsePathUuid(c, "groupId")
+	meta := parseRequestMeta(c)
+	h.contactService.DeleteGroup(meta.UserID, id)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *contactHandler) AddToGroup(c *gin.Context) {
+	id := parsePathUuid(c, "groupId")
+	contactID := parseFormUuid(c, "contactId")
+	meta := parseRequestMeta(c)
+	h.contactService.AddToGroup(meta.UserID, id, contactID)
+	c.Status(http.StatusOK)
+}
+
+func (h *contactHandler) RemoveFromGroup(c *gin.Context) {
+	id := parsePathUuid(c, "groupId")
+	contactID := parsePathUuid(c, "contactId")
+	meta := parseRequestMeta(c)
+	h.contactService.RemoveFromGroup(meta.UserID, id, contactID)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *contactHandler) GetBlocked(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.contactService.GetBlocked(meta.UserID))
+}
+
+func (h *contactHandler) Block(c *gin.Context) {
+	email := parseFormParam(c, "email")
+	meta := parseRequestMeta(c)
+	h.contactService.Block(meta.UserID, email)
+	c.Status(http.StatusOK)
+}
+
+func (h *contactHandler) Unblock(c *gin.Context) {
+	id := parsePathUuid(c, "userId")
+	meta := parseRequestMeta(c)
+	h.contactService.Unblock(meta.UserID, id)
+	c.Status(http.StatusNoContent)
+}
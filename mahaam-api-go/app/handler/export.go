@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"mahaam-api/app/models"
+	"mahaam-api/app/service"
+	"mahaam-api/utils/export"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ExportHandler interface {
+	Export(c *gin.Context)
+}
+
+type exportHandler struct {
+	exportService service.ExportService
+}
+
+func NewExportHandler(exportService service.ExportService) ExportHandler {
+	return &exportHandler{exportService: exportService}
+}
+
+func RegisterExportHandler(router *gin.RouterGroup, h ExportHandler) {
+	withScopes(router, readScopes).GET("/export", h.Export)
+}
+
+// Export streams all plans, or the one in the optional planId query param, as json, csv, md or ics
+func (h *exportHandler) Export(c *gin.Context) {
+	format := strings.ToLower(parseQueryParam(c, "format"))
+	if !export.IsFormat(format) {
+		panic(models.InputError("format should be one of json, csv, md, ics"))
+	}
+	var planID *uuid.UUID
+	if value := c.Query("planId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			panic(models.InputError("planId is not valid uuid"))
+		}
+		planID = &id
+	}
+	meta := parseRequestMeta(c)
+
+	w := &attachmentWriter{c: c, contentType: export.ContentType(models.ExportFormat(format)), fileName: "mahaam-plans." + format}
+	h.exportService.Export(meta.UserID, planID, models.ExportFormat(format), w)
+}
+
+// attachmentWriter sets the download headers on the first write,
+// so errors raised before any output are still answered as json by the recovery middleware
+type attachmentWriter struct {
+	c           *gin.Context
+	contentType string
+	fileName    string
+	started     bool
+}
+
+func (w *attachmentWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		if w.fileName != "" {
+			w.c.Header("Content-Disposition", `attachment; filename="`+w.fileName+`"`)
+		}
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(b)
+}
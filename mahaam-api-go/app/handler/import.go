@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"mahaam-api/app/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler interface {
+	Import(c *gin.Context)
+}
+
+type importHandler struct {
+	importService service.ImportService
+}
+
+func NewImportHandler(importService service.ImportService) ImportHandler {
+	return &importHandler{importService: importService}
+}
+
+func RegisterImportHandler(router *gin.RouterGroup, h ImportHandler) {
+	withScopes(router, plansScopes).POST("/import", h.Import)
+}
+
+const importMaxSize = 1 << 20
+
+// Import reads plans from an uploaded file or the content form param, in json, csv or md format.
+// With dryRun=true nothing is created and the report lists the validation errors.
+func (h *importHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, importMaxSize)
+	format := models.ExportFormat(strings.ToLower(parseFormParam(c, "format")))
+	if format != models.ExportFormatJSON && format != models.ExportFormatCSV && format != models.ExportFormatMarkdown {
+		panic(models.InputError("format should be one of json, csv, md"))
+	}
+	dryRun := false
+	if c.PostForm("dryRun") != "" {
+		dryRun = parseFormBool(c, "dryRun")
+	}
+	meta := parseRequestMeta(c)
+
+	var content io.Reader
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			panic(models.InputError("file cannot be read"))
+		}
+		defer f.Close()
+		content = f
+	} else {
+		value := c.PostForm("content")
+		if strings.TrimSpace(value) == "" {
+			panic(models.InputError("file or content is required"))
+		}
+		content = strings.NewReader(value)
+	}
+
+	report := h.importService.Import(meta.UserID, format, content, dryRun)
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
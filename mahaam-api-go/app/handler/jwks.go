@@ -0,0 +1,30 @@
+package handler
+
+import (
+	token "mahaam-api/utils/token"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JwksHandler interface {
+	GetKeys(c *gin.Context)
+}
+
+type jwksHandler struct {
+	tokenService token.TokenService
+}
+
+func NewJwksHandler(tokenService token.TokenService) JwksHandler {
+	return &jwksHandler{tokenService: tokenService}
+}
+
+// RegisterJwksHandler serves the public keys at the root, outside the app-header and auth checks
+func RegisterJwksHandler(router *gin.RouterGroup, h JwksHandler) {
+	router.GET("/.well-known/jwks.json", h.GetKeys)
+}
+
+func (h *jwksHandler) GetKeys(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, h.tokenService.JWKS())
+}
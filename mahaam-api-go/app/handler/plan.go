@@ -5,7 +5,7 @@ import (
 	"mahaam-api/app/service"
 	logs "mahaam-api/utils/log"
 	"net/http"
-	"strings"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,8 +17,12 @@ type PlanHandler interface {
 	Delete(c *gin.Context)
 	Share(c *gin.Context)
 	Unshare(c *gin.Context)
+	Invite(c *gin.Context)
 	Leave(c *gin.Context)
 	UpdateType(c *gin.Context)
+	UpdateStatus(c *gin.Context)
+	Pin(c *gin.Context)
+	Unpin(c *gin.Context)
 	ReOrder(c *gin.Context)
 	GetOne(c *gin.Context)
 	GetMany(c *gin.Context)
@@ -35,17 +39,23 @@ func NewPlanHandler(service service.PlanService, logger logs.Logger) PlanHandler
 
 func RegisterPlanHandler(router *gin.RouterGroup, h PlanHandler) {
 	planRouter := router.Group("/plans")
-
-	planRouter.POST("", h.Create)
-	planRouter.PUT("", h.Update)
-	planRouter.DELETE("/:planId", h.Delete)
-	planRouter.PATCH("/:planId/share", h.Share)
-	planRouter.PATCH("/:planId/unshare", h.Unshare)
-	planRouter.PATCH("/:planId/leave", h.Leave)
-	planRouter.PATCH("/:planId/type", h.UpdateType)
-	planRouter.PATCH("/reorder", h.ReOrder)
-	planRouter.GET("/:planId", h.GetOne)
-	planRouter.GET("", h.GetMany)
+	writes := withScopes(planRouter, plansScopes)
+	reads := withScopes(planRouter, readScopes)
+
+	writes.POST("", h.Create)
+	writes.PUT("", h.Update)
+	writes.DELETE("/:planId", h.Delete)
+	writes.PATCH("/:planId/share", h.Share)
+	writes.PATCH("/:planId/unshare", h.Unshare)
+	writes.POST("/:planId/invite", h.Invite)
+	writes.PATCH("/:planId/leave", h.Leave)
+	writes.PATCH("/:planId/type", h.UpdateType)
+	writes.PATCH("/:planId/status", h.UpdateStatus)
+	writes.PATCH("/:planId/pin", h.Pin)
+	writes.PATCH("/:planId/unpin", h.Unpin)
+	writes.PATCH("/reorder", h.ReOrder)
+	reads.GET("/:planId", h.GetOne)
+	reads.GET("", h.GetMany)
 
 }
 
@@ -80,10 +90,16 @@ func (h *planHandler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// Share shares the plan with an email, or with the contacts of a group when groupId is sent
 func (h *planHandler) Share(c *gin.Context) {
 	id := parsePathUuid(c, "planId")
-	email := parseFormParam(c, "email")
 	meta := parseRequestMeta(c)
+	if c.PostForm("groupId") != "" {
+		groupID := parseFormUuid(c, "groupId")
+		c.JSON(http.StatusOK, h.planService.ShareWithGroup(meta.UserID, id, groupID))
+		return
+	}
+	email := parseFormParam(c, "email")
 	h.planService.Share(meta.UserID, id, email)
 	c.Status(http.StatusOK)
 }
@@ -96,6 +112,14 @@ func (h *planHandler) Unshare(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (h *planHandler) Invite(c *gin.Context) {
+	id := parsePathUuid(c, "planId")
+	email := parseFormParam(c, "email")
+	meta := parseRequestMeta(c)
+	h.planService.Invite(meta.UserID, id, email)
+	c.Status(http.StatusAccepted)
+}
+
 func (h *planHandler) Leave(c *gin.Context) {
 	id := parsePathUuid(c, "planId")
 	meta := parseRequestMeta(c)
@@ -107,12 +131,33 @@ func (h *planHandler) Leave(c *gin.Context) {
 func (h *planHandler) UpdateType(c *gin.Context) {
 	id := parsePathUuid(c, "planId")
 	planType := parseFormParam(c, "type")
-	validatePlanType(planType)
 	meta := parseRequestMeta(c)
 	h.planService.UpdateType(meta.UserID, id, planType)
 	c.Status(http.StatusOK)
 }
 
+func (h *planHandler) UpdateStatus(c *gin.Context) {
+	id := parsePathUuid(c, "planId")
+	status := parseFormParam(c, "status")
+	meta := parseRequestMeta(c)
+	h.planService.UpdateStatus(meta.UserID, id, status)
+	c.Status(http.StatusOK)
+}
+
+func (h *planHandler) Pin(c *gin.Context) {
+	id := parsePathUuid(c, "planId")
+	meta := parseRequestMeta(c)
+	h.planService.Pin(meta.UserID, id)
+	c.Status(http.StatusOK)
+}
+
+func (h *planHandler) Unpin(c *gin.Context) {
+	id := parsePathUuid(c, "planId")
+	meta := parseRequestMeta(c)
+	h.planService.Unpin(meta.UserID, id)
+	c.Status(http.StatusOK)
+}
+
 func (h *planHandler) ReOrder(c *gin.Context) {
 	var input struct {
 		Type     string `form:"type" binding:"gte=0"`
@@ -120,8 +165,6 @@ func (h *planHandler) ReOrder(c *gin.Context) {
 		NewIndex int    `form:"newOrder" binding:"required"`
 	}
 	parse(c, &input)
-
-	validatePlanType(input.Type)
 	meta := parseRequestMeta(c)
 	h.planService.ReOrder(meta.UserID, input.Type, input.OldIndex, input.NewIndex)
 	c.Status(http.StatusOK)
@@ -139,18 +182,22 @@ func (h *planHandler) GetOne(c *gin.Context) {
 
 func (h *planHandler) GetMany(c *gin.Context) {
 	planType := parseQueryParam(c, "type")
-	validatePlanType(planType)
 	meta := parseRequestMeta(c)
-	plans := h.planService.GetMany(meta.UserID, planType)
-	c.JSON(http.StatusOK, plans)
-}
-
-func validatePlanType(t string) {
-	validTypes := []string{string(PlanTypeMain), string(PlanTypeArchived)} // Add other types if defined
-	for _, vt := range validTypes {
-		if strings.EqualFold(t, vt) {
-			return
+	q, ok := parseListQuery(c, models.ListSortManual, models.ListSortCreated, models.ListSortUpdated, models.ListSortDue)
+	// due filters plans ending overdue, today, or this week of the user
+	if due := c.Query("due"); due != "" {
+		if !slices.Contains(models.DueFilters, due) {
+			panic(models.InputError("due is not valid"))
+		}
+		if !ok {
+			q = models.ListQuery{Sort: models.ListSortManual, Limit: defaultPageLimit}
 		}
+		q.Due, ok = due, true
 	}
-	panic(models.InputError("Invalid plan type"))
+	if ok {
+		c.JSON(http.StatusOK, h.planService.GetPage(meta.UserID, planType, q))
+		return
+	}
+	plans := h.planService.GetMany(meta.UserID, planType)
+	c.JSON(http.StatusOK, plans)
 }
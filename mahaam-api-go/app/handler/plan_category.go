@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlanCategoryHandler interface {
+	Create(c *gin.Context)
+	Rename(c *gin.Context)
+	Delete(c *gin.Context)
+	ReOrder(c *gin.Context)
+	GetMany(c *gin.Context)
+}
+
+type planCategoryHandler struct {
+	planCategoryService service.PlanCategoryService
+}
+
+func NewPlanCategoryHandler(planCategoryService service.PlanCategoryService) PlanCategoryHandler {
+	return &planCategoryHandler{planCategoryService: planCategoryService}
+}
+
+func RegisterPlanCategoryHandler(router *gin.RouterGroup, h PlanCategoryHandler) {
+	categoryRouter := router.Group("/plan-categories")
+
+	writes := withScopes(categoryRouter, plansScopes)
+
+	writes.POST("", h.Create)
+	writes.PATCH("/:categoryId/name", h.Rename)
+	writes.DELETE("/:categoryId", h.Delete)
+	writes.PATCH("/reorder", h.ReOrder)
+	withScopes(categoryRouter, readScopes).GET("", h.GetMany)
+}
+
+func (h *planCategoryHandler) Create(c *gin.Context) {
+	name := parseFormParam(c, "name")
+	meta := parseRequestMeta(c)
+	id := h.planCategoryService.Create(meta.UserID, name)
+	c.JSON(http.StatusCreated, id)
+}
+
+func (h *planCategoryHandler) Rename(c *gin.Context) {
+	id := parsePathUuid(c, "categoryId")
+	name := parseFormParam(c, "name")
+	meta := parseRequestMeta(c)
+	h.planCategoryService.Rename(meta.UserID, id, name)
+	c.Status(http.StatusOK)
+}
+
+func (h *planCategoryHandler) Delete(c *gin.Context) {
+	id := parsePathUuid(c, "categoryId")
+	meta := parseRequestMeta(c)
+	h.planCategoryService.Delete(meta.UserID, id)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *planCategoryHandler) ReOrder(c *gin.Context) {
+	oldOrder := parseFormInt(c, "oldOrder")
+	newOrder := parseFormInt(c, "newOrder")
+	meta := parseRequestMeta(c)
+	h.planCategoryService.ReOrder(meta.UserID, oldOrder, newOrder)
+	c.Status(http.StatusOK)
+}
+
+func (h *planCategoryHandler) GetMany(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	categories := h.planCategoryService.GetMany(meta.UserID)
+	c.JSON(http.StatusOK, categories)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PreferencesHandler interface {
+	Get(c *gin.Context)
+	Update(c *gin.Context)
+}
+
+type preferencesHandler struct {
+	preferencesService service.PreferencesService
+}
+
+func NewPreferencesHandler(preferencesService service.PreferencesService) PreferencesHandler {
+	return &preferencesHandler{preferencesService: preferencesService}
+}
+
+func RegisterPreferencesHandler(router *gin.RouterGroup, h PreferencesHandler) {
+	rg := router.Group("/users/preferences")
+	rg.GET("", h.Get)
+	rg.PATCH("", h.Update)
+}
+
+func (h *preferencesHandler) Get(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.preferencesService.Get(meta.UserID))
+}
+
+// Update takes a json body with only the preferences to change
+func (h *preferencesHandler) Update(c *gin.Context) {
+	var in PreferencesIn
+	parse(c, &in)
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, h.preferencesService.Update(meta.UserID, in))
+}
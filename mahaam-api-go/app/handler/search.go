@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler interface {
+	Search(c *gin.Context)
+}
+
+type searchHandler struct {
+	searchService service.SearchService
+}
+
+func NewSearchHandler(searchService service.SearchService) SearchHandler {
+	return &searchHandler{searchService: searchService}
+}
+
+func RegisterSearchHandler(router *gin.RouterGroup, h SearchHandler) {
+	withScopes(router, readScopes).GET("/search", h.Search)
+}
+
+func (h *searchHandler) Search(c *gin.Context) {
+	q := parseQueryParam(c, "q")
+	meta := parseRequestMeta(c)
+	results := h.searchService.Search(meta.UserID, q)
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"mahaam-api/app/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TakeoutHandler interface {
+	Request(c *gin.Context)
+	Get(c *gin.Context)
+	Download(c *gin.Context)
+}
+
+type takeoutHandler struct {
+	takeoutService service.TakeoutService
+}
+
+func NewTakeoutHandler(takeoutService service.TakeoutService) TakeoutHandler {
+	return &takeoutHandler{takeoutService: takeoutService}
+}
+
+func RegisterTakeoutHandler(router *gin.RouterGroup, h TakeoutHandler) {
+	router.POST("/users/takeout", h.Request)
+	router.GET("/users/takeout/:jobId", h.Get)
+
+	// bypasses jwt auth, the signed url is the only credential
+	router.GET("/takeout/:jobId", h.Download)
+}
+
+// Request queues a zip of all the user's data, poll Get for its status and download url
+func (h *takeoutHandler) Request(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusAccepted, h.takeoutService.Request(meta.UserID))
+}
+
+func (h *takeoutHandler) Get(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	id := parsePathUuid(c, "jobId")
+	c.JSON(http.StatusOK, h.takeoutService.Get(meta.UserID, id))
+}
+
+func (h *takeoutHandler) Download(c *gin.Context) {
+	id := parsePathUuid(c, "jobId")
+	expires := parseQueryParam(c, "expires")
+	signature := parseQueryParam(c, "signature")
+	path := h.takeoutService.Download(id, expires, signature)
+	c.Header("Content-Type", "application/zip")
+	c.FileAttachment(path, "mahaam-takeout.zip")
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"mahaam-api/app/models"
 	"mahaam-api/app/service"
 	"net/http"
 
@@ -12,6 +13,7 @@ type TaskHandler interface {
 	Delete(c *gin.Context)
 	UpdateDone(c *gin.Context)
 	UpdateTitle(c *gin.Context)
+	UpdateDue(c *gin.Context)
 	ReOrder(c *gin.Context)
 	GetMany(c *gin.Context)
 }
@@ -27,12 +29,16 @@ func NewTaskHandler(taskService service.TaskService) TaskHandler {
 func RegisterTaskHandler(router *gin.RouterGroup, h TaskHandler) {
 	taskRouter := router.Group("/plans/:planId/tasks", ValidatePlanId)
 
-	taskRouter.POST("/", h.Create)
-	taskRouter.DELETE("/:taskId", h.Delete)
-	taskRouter.PATCH("/:taskId/done", h.UpdateDone)
-	taskRouter.PATCH("/:taskId/title", h.UpdateTitle)
-	taskRouter.PATCH("/reorder", h.ReOrder)
-	taskRouter.GET("", h.GetMany)
+	writes := withScopes(taskRouter, tasksScopes)
+	reads := withScopes(taskRouter, readScopes)
+
+	writes.POST("/", h.Create)
+	writes.DELETE("/:taskId", h.Delete)
+	writes.PATCH("/:taskId/done", h.UpdateDone)
+	writes.PATCH("/:taskId/title", h.UpdateTitle)
+	writes.PATCH("/:taskId/due", h.UpdateDue)
+	writes.PATCH("/reorder", h.ReOrder)
+	reads.GET("", h.GetMany)
 }
 
 func ValidatePlanId(c *gin.Context) {
@@ -68,6 +74,14 @@ func (h *taskHandler) UpdateTitle(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// UpdateDue sets the task due date as yyyy-MM-dd, or clears it when due is empty
+func (h *taskHandler) UpdateDue(c *gin.Context) {
+	id := parsePathUuid(c, "taskId")
+	due := parseFormDate(c, "due")
+	h.taskService.UpdateDue(id, due)
+	c.Status(http.StatusOK)
+}
+
 func (h *taskHandler) ReOrder(c *gin.Context) {
 	planID := parsePathUuid(c, "planId")
 	oldOrder := parseFormInt(c, "oldOrder")
@@ -78,6 +92,10 @@ func (h *taskHandler) ReOrder(c *gin.Context) {
 
 func (h *taskHandler) GetMany(c *gin.Context) {
 	planID := parsePathUuid(c, "planId")
+	if q, ok := parseListQuery(c, models.ListSortManual, models.ListSortCreated, models.ListSortUpdated, models.ListSortDue); ok {
+		c.JSON(http.StatusOK, h.taskService.GetPage(planID, q))
+		return
+	}
 	tasks := h.taskService.GetList(planID)
 	c.JSON(http.StatusOK, tasks)
 }
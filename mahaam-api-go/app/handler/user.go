@@ -4,6 +4,7 @@ import (
 	"io"
 	"mahaam-api/app/models"
 	"mahaam-api/app/service"
+	"mahaam-api/utils/attest"
 	logs "mahaam-api/utils/log"
 	"mahaam-api/utils/middleware"
 	"net/http"
@@ -17,10 +18,17 @@ type UserHandler interface {
 	SendMeOtp(c *gin.Context)
 	VerifyOtp(c *gin.Context)
 	RefreshToken(c *gin.Context)
+	RotateToken(c *gin.Context)
 	UpdateName(c *gin.Context)
+	StartEmailChange(c *gin.Context)
+	ChangeEmail(c *gin.Context)
 	Logout(c *gin.Context)
+	LogoutOthers(c *gin.Context)
+	RenameDevice(c *gin.Context)
 	Delete(c *gin.Context)
 	GetDevices(c *gin.Context)
+	StartOidc(c *gin.Context)
+	VerifyOidc(c *gin.Context)
 	GetSuggestedEmails(c *gin.Context)
 	DeleteSuggestedEmail(c *gin.Context)
 }
@@ -43,9 +51,16 @@ func RegisterUserHandler(router *gin.RouterGroup, h UserHandler) {
 	rg.POST("/create", createLimiter, h.Create)
 	rg.POST("/send-me-otp", createLimiter, h.SendMeOtp)
 	rg.POST("/verify-otp", h.VerifyOtp)
+	rg.POST("/oidc/start", h.StartOidc)
+	rg.POST("/oidc/verify", h.VerifyOidc)
 	rg.POST("/refresh-token", h.RefreshToken)
+	rg.POST("/rotate-token", h.RotateToken)
 	rg.PATCH("/name", h.UpdateName)
+	rg.POST("/email-change", createLimiter, h.StartEmailChange)
+	rg.PATCH("/email", h.ChangeEmail)
 	rg.POST("/logout", h.Logout)
+	rg.POST("/logout-others", h.LogoutOthers)
+	rg.PATCH("/devices/:deviceId/name", h.RenameDevice)
 	rg.DELETE("", h.Delete)
 	rg.GET("/devices", h.GetDevices)
 	rg.GET("/suggested-emails", h.GetSuggestedEmails)
@@ -57,9 +72,9 @@ func (r *userHandler) Create(c *gin.Context) {
 	isPhysicalDevice := parseFormBool(c, "isPhysicalDevice")
 	deviceFingerprint := parseFormParam(c, "deviceFingerprint")
 	deviceInfo := parseFormParam(c, "deviceInfo")
-
-	if !isPhysicalDevice {
-		panic(models.InputError("Device should be real, not a simulator"))
+	attestation := attest.Request{
+		Token:           c.PostForm("attestationToken"),
+		ClaimedPhysical: isPhysicalDevice,
 	}
 
 	device := models.Device{
@@ -67,7 +82,7 @@ func (r *userHandler) Create(c *gin.Context) {
 		Fingerprint: deviceFingerprint,
 		Info:        deviceInfo,
 	}
-	createdUser := r.userService.Create(device)
+	createdUser := r.userService.Create(device, attestation)
 	r.logger.Info(parseTrafficID(c), "User Created with id:%s, deviceId:%s.", createdUser.ID, createdUser.DeviceID)
 
 	c.JSON(http.StatusOK, createdUser)
@@ -75,7 +90,8 @@ func (r *userHandler) Create(c *gin.Context) {
 
 func (r *userHandler) SendMeOtp(c *gin.Context) {
 	email := parseFormParam(c, "email")
-	verificationSid := r.userService.SendMeOtp(email)
+	meta := parseRequestMeta(c)
+	verificationSid := r.userService.SendMeOtp(meta, email)
 	r.logger.Info(parseTrafficID(c), "OTP sent to %s", email)
 	c.JSON(http.StatusOK, verificationSid)
 }
@@ -90,12 +106,36 @@ func (r *userHandler) VerifyOtp(c *gin.Context) {
 	c.JSON(http.StatusOK, verifiedUser)
 }
 
+func (r *userHandler) StartOidc(c *gin.Context) {
+	provider := parseFormParam(c, "provider")
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, r.userService.StartOidc(meta, provider))
+}
+
+// VerifyOidc takes the state and code the identity provider redirected the app back with
+func (r *userHandler) VerifyOidc(c *gin.Context) {
+	state := parseFormParam(c, "state")
+	code := parseFormParam(c, "code")
+	meta := parseRequestMeta(c)
+	verifiedUser := r.userService.VerifyOidc(meta, state, code)
+	r.logger.Info(parseTrafficID(c), "OIDC login verified for %s", verifiedUser.UserID)
+	c.JSON(http.StatusOK, verifiedUser)
+}
+
+// RefreshToken gives apps logged in before refresh tokens their first refresh token, in exchange of their access token
 func (r *userHandler) RefreshToken(c *gin.Context) {
 	meta := parseRequestMeta(c)
 	verifiedUser := r.userService.RefreshToken(meta)
 	c.JSON(http.StatusOK, verifiedUser)
 }
 
+// RotateToken exchanges a refresh token for new access and refresh tokens, it needs no access token
+func (r *userHandler) RotateToken(c *gin.Context) {
+	refreshToken := parseFormParam(c, "refreshToken")
+	verifiedUser := r.userService.RotateRefreshToken(refreshToken)
+	c.JSON(http.StatusOK, verifiedUser)
+}
+
 func (r *userHandler) UpdateName(c *gin.Context) {
 	name := parseFormParam(c, "name")
 	meta := parseRequestMeta(c)
@@ -103,6 +143,25 @@ func (r *userHandler) UpdateName(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (r *userHandler) StartEmailChange(c *gin.Context) {
+	newEmail := parseFormParam(c, "newEmail")
+	meta := parseRequestMeta(c)
+	c.JSON(http.StatusOK, r.userService.StartEmailChange(meta, newEmail))
+}
+
+// ChangeEmail takes the otps sent by StartEmailChange to the current and the new email
+func (r *userHandler) ChangeEmail(c *gin.Context) {
+	newEmail := parseFormParam(c, "newEmail")
+	oldSid := parseFormParam(c, "oldEmailSid")
+	oldOtp := parseFormParam(c, "oldEmailOtp")
+	newSid := parseFormParam(c, "newEmailSid")
+	newOtp := parseFormParam(c, "newEmailOtp")
+	meta := parseRequestMeta(c)
+	email := r.userService.ChangeEmail(meta, newEmail, oldSid, oldOtp, newSid, newOtp)
+	r.logger.Info(parseTrafficID(c), "Email changed for %s", meta.UserID)
+	c.JSON(http.StatusOK, email)
+}
+
 func (r *userHandler) Logout(c *gin.Context) {
 	deviceId := parseFormUuid(c, "deviceId")
 	meta := parseRequestMeta(c)
@@ -110,6 +169,20 @@ func (r *userHandler) Logout(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+func (r *userHandler) LogoutOthers(c *gin.Context) {
+	meta := parseRequestMeta(c)
+	r.userService.LogoutOthers(meta)
+	c.Status(http.StatusOK)
+}
+
+func (r *userHandler) RenameDevice(c *gin.Context) {
+	deviceId := parsePathUuid(c, "deviceId")
+	name := parseFormParam(c, "name")
+	meta := parseRequestMeta(c)
+	r.userService.RenameDevice(meta.UserID, deviceId, name)
+	c.Status(http.StatusOK)
+}
+
 func (r *userHandler) Delete(c *gin.Context) {
 	// Parsed this way as the body is not parsed by the framework for DELETE requests
 	body, _ := io.ReadAll(c.Request.Body)
@@ -121,13 +194,16 @@ func (r *userHandler) Delete(c *gin.Context) {
 	}
 
 	meta := parseRequestMeta(c)
-	r.userService.Delete(meta.UserID, sid, otp)
+	if deletion := r.userService.Delete(meta, sid, otp); deletion != nil {
+		c.JSON(http.StatusAccepted, deletion)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
 func (r *userHandler) GetDevices(c *gin.Context) {
 	meta := parseRequestMeta(c)
-	devices := r.userService.GetDevices(meta.UserID)
+	devices := r.userService.GetDevices(meta)
 	c.JSON(http.StatusOK, devices)
 }
 
@@ -2,10 +2,15 @@ package handler
 
 import (
 	"mahaam-api/app/models"
+	token "mahaam-api/utils/token"
 
 	"fmt"
+	"net/http"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,17 +22,23 @@ type SuggestedEmail = models.SuggestedEmail
 type Device = models.Device
 type Plan = models.Plan
 type PlanIn = models.PlanIn
+type PlanCategory = models.PlanCategory
 type Task = models.Task
 type User = models.User
 type CreatedUser = models.CreatedUser
 type VerifiedUser = models.VerifiedUser
 type Meta = models.Meta
+type PreferencesIn = models.PreferencesIn
 type PlanType = models.PlanType
 
 const PlanTypeMain = models.PlanTypeMain
 const PlanTypeArchived = models.PlanTypeArchived
 
 func parseRequestMeta(c *gin.Context) Meta {
+	// requests with personal access tokens have no device
+	if _, ok := c.Value("accessTokenId").(uuid.UUID); ok {
+		return Meta{UserID: parseUserID(c)}
+	}
 	return Meta{
 		UserID:   parseUserID(c),
 		DeviceID: parseDeviceID(c),
@@ -109,6 +120,18 @@ func parseFormBool(c *gin.Context, param string) bool {
 	return val
 }
 
+// parseFormDate parses an optional yyyy-MM-dd date, nil when not sent
+func parseFormDate(c *gin.Context, param string) *string {
+	value := strings.TrimSpace(c.PostForm(param))
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		panic(models.InputError(param + " is not valid date"))
+	}
+	return &value
+}
+
 func parsePathUuid(c *gin.Context, param string) uuid.UUID {
 	value := parsePathParam(c, param)
 	id, err := uuid.Parse(value)
@@ -140,8 +163,111 @@ func joinErrors(ves validator.ValidationErrors) string {
 	return strings.Join(messages, " ")
 }
 
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// parseListQuery reads the optional limit, cursor, sort and done query params.
+// ok is false when none of them is sent, so clients not aware of paging keep getting plain arrays.
+func parseListQuery(c *gin.Context, sorts ...models.ListSort) (q models.ListQuery, ok bool) {
+	limit, cursor, sort, done := c.Query("limit"), c.Query("cursor"), c.Query("sort"), c.Query("done")
+	if limit == "" && cursor == "" && sort == "" && done == "" {
+		return q, false
+	}
+
+	q.Limit = defaultPageLimit
+	if limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxPageLimit {
+			panic(models.InputError(fmt.Sprintf("limit should be between 1 and %d", maxPageLimit)))
+		}
+		q.Limit = value
+	}
+
+	if cursor != "" {
+		value, err := models.DecodeCursor(cursor)
+		if err != nil {
+			panic(models.InputError("cursor is not valid"))
+		}
+		q.Cursor = value
+	}
+
+	q.Sort = models.ListSortManual
+	if sort != "" {
+		if !slices.Contains(sorts, models.ListSort(sort)) {
+			panic(models.InputError("sort is not valid"))
+		}
+		q.Sort = models.ListSort(sort)
+	}
+	if q.Cursor != nil && q.Cursor.Sort != q.Sort {
+		panic(models.InputError("cursor is not valid"))
+	}
+
+	if done != "" {
+		value, err := strconv.ParseBool(done)
+		if err != nil {
+			panic(models.InputError("done is not valid boolean"))
+		}
+		q.Done = &value
+	}
+	return q, true
+}
+
 func requiredParam(value any, param string) {
 	if value == nil {
 		panic(models.InputError(param + " is required"))
 	}
 }
+
+// personal access token scopes of routes, every scope can read
+var (
+	readScopes  = []string{models.AccessTokenScopeRead, models.AccessTokenScopeTasks, models.AccessTokenScopePlans}
+	tasksScopes = []string{models.AccessTokenScopeTasks}
+	plansScopes = []string{models.AccessTokenScopePlans}
+)
+
+// scopedRoutes registers routes personal access tokens having one of the scopes can call,
+// routes registered on the router directly are not reachable with a personal access token
+type scopedRoutes struct {
+	router *gin.RouterGroup
+	scopes []string
+}
+
+func withScopes(router *gin.RouterGroup, scopes []string) scopedRoutes {
+	return scopedRoutes{router: router, scopes: scopes}
+}
+
+func (r scopedRoutes) handle(method, relativePath string, handlers ...gin.HandlerFunc) {
+	r.router.Handle(method, relativePath, handlers...)
+	token.AllowScopes(method, fullPath(r.router, relativePath), r.scopes...)
+}
+
+// fullPath is the route path as gin reports it in c.FullPath(), keeping a trailing slash
+func fullPath(router *gin.RouterGroup, relativePath string) string {
+	joined := path.Join(router.BasePath(), relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
+
+func (r scopedRoutes) GET(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, relativePath, handlers...)
+}
+
+func (r scopedRoutes) POST(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, relativePath, handlers...)
+}
+
+func (r scopedRoutes) PUT(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, relativePath, handlers...)
+}
+
+func (r scopedRoutes) PATCH(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPatch, relativePath, handlers...)
+}
+
+func (r scopedRoutes) DELETE(relativePath string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, relativePath, handlers...)
+}
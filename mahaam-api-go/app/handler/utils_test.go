@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFullPath checks scopes are declared under the path gin matches the route with
+func TestFullPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name         string
+		group        string
+		relativePath string
+		request      string
+	}{
+		{"empty path", "/plans", "", "/mahaam-api/plans"},
+		{"param", "/plans", "/:planId", "/mahaam-api/plans/1"},
+		{"trailing slash", "/plans/:planId/tasks", "/", "/mahaam-api/plans/1/tasks/"},
+		{"root group", "", "/search", "/mahaam-api/search"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			group := router.Group("/mahaam-api").Group(tt.group)
+			var matched string
+			group.GET(tt.relativePath, func(c *gin.Context) { matched = c.FullPath() })
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.request, nil))
+			if got := fullPath(group, tt.relativePath); got != matched {
+				t.Errorf("fullPath() = %q, gin matched %q", got, matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Scopes of personal access tokens, every scope can read, "tasks" and "plans" can also change them
+const (
+	AccessTokenScopeRead  = "read"
+	AccessTokenScopeTasks = "tasks"
+	AccessTokenScopePlans = "plans"
+)
+
+// AccessTokenPrefix tells personal access tokens apart from jwts in the Authorization header
+const AccessTokenPrefix = "mhm_pat_"
+
+// AccessToken is a user-created token for scripts, kept hashed. The token is only returned when it is created.
+type AccessToken struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	UserID     uuid.UUID      `json:"-" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	Token      string         `json:"token,omitempty" db:"-"`
+	CreatedAt  time.Time      `json:"createdAt" db:"created_at"`
+	ExpiresAt  *time.Time     `json:"expiresAt,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"lastUsedAt,omitempty" db:"last_used_at"`
+}
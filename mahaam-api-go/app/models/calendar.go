@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarFeed is the user's subscribable ics url, the token is only returned when the feed is created or rotated
+type CalendarFeed struct {
+	UserID         uuid.UUID  `json:"-" db:"user_id"`
+	Token          string     `json:"token,omitempty" db:"-"`
+	Path           string     `json:"path,omitempty" db:"-"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty" db:"last_accessed_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContactGroup is a named list of the user's contacts a plan can be shared with at once
+type ContactGroup struct {
+	ID        uuid.UUID        `json:"id" db:"id"`
+	UserID    uuid.UUID        `json:"-" db:"user_id"`
+	Name      string           `json:"name" db:"name"`
+	Contacts  []SuggestedEmail `json:"contacts" db:"-"`
+	CreatedAt time.Time        `json:"createdAt" db:"created_at"`
+}
+
+// ContactGroupMember links a contact to a group, used to load the contacts of many groups at once
+type ContactGroupMember struct {
+	GroupID uuid.UUID `db:"group_id"`
+	SuggestedEmail
+}
+
+// BlockedUser is a user who cannot share plans with the blocking user
+type BlockedUser struct {
+	UserID    uuid.UUID `json:"userId" db:"blocked_user_id"`
+	Email     *string   `json:"email" db:"email"`
+	Name      *string   `json:"name,omitempty" db:"name"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// GroupShare is the result of sharing a plan with a contact group, contacts without an account,
+// already members or blocking the sharer are skipped
+type GroupShare struct {
+	Shared  []string `json:"shared"`
+	Skipped []string `json:"skipped"`
+}
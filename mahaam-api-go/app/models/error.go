@@ -2,13 +2,25 @@ package models
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"time"
 )
 
 type Err struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Key     string `json:"key"`
+	Code       int          `json:"code"`
+	Message    string       `json:"message"`
+	Key        string       `json:"key"`
+	RetryAfter int          `json:"retryAfter,omitempty"`
+	Upgrade    *UpgradeInfo `json:"upgrade,omitempty"`
+}
+
+// UpgradeInfo tells a too old app which version to upgrade to and where
+type UpgradeInfo struct {
+	Store              string `json:"store"`
+	MinimumVersion     string `json:"minimumVersion"`
+	RecommendedVersion string `json:"recommendedVersion,omitempty"`
+	UpgradeURL         string `json:"upgradeUrl,omitempty"`
 }
 
 func (e *Err) Error() string {
@@ -55,6 +67,27 @@ func LogicError(message, key string) *Err {
 	}
 }
 
+// TooManyAttemptsError tells the client to retry after the given duration, RetryAfter is in seconds
+func TooManyAttemptsError(key string, retryAfter time.Duration) *Err {
+	minutes := int(math.Ceil(retryAfter.Minutes()))
+	return &Err{
+		Code:       http.StatusTooManyRequests,
+		Message:    fmt.Sprintf("Too many attempts, try again in %d minutes", minutes),
+		Key:        key,
+		RetryAfter: int(math.Ceil(retryAfter.Seconds())),
+	}
+}
+
+// UpgradeRequiredError rejects app versions below the minimum supported version of their store
+func UpgradeRequiredError(upgrade *UpgradeInfo) *Err {
+	return &Err{
+		Code:    http.StatusUpgradeRequired,
+		Message: "This app version is no longer supported, please upgrade",
+		Key:     "upgrade_required",
+		Upgrade: upgrade,
+	}
+}
+
 func ServerError(message string) *Err {
 	return &Err{
 		Code:    http.StatusInternalServerError,
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlanTaskRow is a plan joined with one of its tasks, task fields are nil for plans without tasks
+type PlanTaskRow struct {
+	PlanID     uuid.UUID  `db:"plan_id"`
+	PlanTitle  *string    `db:"plan_title"`
+	PlanType   string     `db:"plan_type"`
+	PlanStatus string     `db:"plan_status"`
+	Starts     *time.Time `db:"starts"`
+	Ends       *time.Time `db:"ends"`
+	IsShared   bool       `db:"is_shared"`
+	CreatedAt  time.Time  `db:"created_at"`
+	TaskID     *uuid.UUID `db:"task_id"`
+	TaskTitle  *string    `db:"task_title"`
+	TaskDone   *bool      `db:"task_done"`
+	TaskDue    *time.Time `db:"task_due"`
+}
+
+type ExportFormat string
+
+const (
+	ExportFormatJSON     ExportFormat = "json"
+	ExportFormatCSV      ExportFormat = "csv"
+	ExportFormatMarkdown ExportFormat = "md"
+	ExportFormatICS      ExportFormat = "ics"
+)
+
+// ExportVersion is increased whenever ExportData changes in a way older importers cannot read
+const ExportVersion = 1
+
+// ExportData is the json export document, also accepted by import
+type ExportData struct {
+	Version int          `json:"version"`
+	Plans   []ExportPlan `json:"plans"`
+}
+
+type ExportPlan struct {
+	Title  *string      `json:"title,omitempty"`
+	Type   string       `json:"type,omitempty"`
+	Status string       `json:"status,omitempty"`
+	Starts *string      `json:"starts,omitempty"`
+	Ends   *string      `json:"ends,omitempty"`
+	Tasks  []ExportTask `json:"tasks"`
+}
+
+type ExportTask struct {
+	Title string  `json:"title"`
+	Done  bool    `json:"done"`
+	Due   *string `json:"due,omitempty"`
+}
+
+// ImportReport tells what an import created, or would create on a dry run
+type ImportReport struct {
+	DryRun  bool        `json:"dryRun"`
+	Plans   int         `json:"plans"`
+	Tasks   int         `json:"tasks"`
+	PlanIDs []uuid.UUID `json:"planIds,omitempty"`
+	Errors  []string    `json:"errors,omitempty"`
+}
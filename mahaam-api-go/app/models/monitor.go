@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Traffic struct {
 	ID       uuid.UUID `json:"id" db:"id"`
@@ -29,3 +33,15 @@ type Health struct {
 	NodeName   string    `json:"nodeName" db:"node_name"`
 	EnvName    string    `json:"envName" db:"env_name"`
 }
+
+// CleanupRun is a run of the abandoned anonymous users cleanup, kept as its metrics
+type CleanupRun struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	HealthID     uuid.UUID `json:"healthId" db:"health_id"`
+	DryRun       bool      `json:"dryRun" db:"dry_run"`
+	InactiveDays int       `json:"inactiveDays" db:"inactive_days"`
+	Candidates   int64     `json:"candidates" db:"candidates"`
+	Deleted      int64     `json:"deleted" db:"deleted"`
+	StartedAt    time.Time `json:"startedAt" db:"started_at"`
+	FinishedAt   time.Time `json:"finishedAt" db:"finished_at"`
+}
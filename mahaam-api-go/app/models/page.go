@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Page is the list envelope returned when a client asks for paging, filtering or sorting
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+type ListSort string
+
+const (
+	ListSortManual  ListSort = "manual"
+	ListSortCreated ListSort = "created"
+	ListSortUpdated ListSort = "updated"
+	ListSortDue     ListSort = "due"
+)
+
+// Due filters of plan lists by their ends date, they are resolved to dates in the user's timezone and week start
+const (
+	DueOverdue = "overdue"
+	DueToday   = "today"
+	DueWeek    = "week"
+)
+
+var DueFilters = []string{DueOverdue, DueToday, DueWeek}
+
+type ListQuery struct {
+	Sort      ListSort
+	Done      *bool
+	Due       string
+	DueFrom   *string
+	DueBefore *string
+	Limit     int
+	Cursor    *Cursor
+}
+
+// Cursor points to the last item of a page by its sort key and id, sent to clients as an opaque string.
+// Sort is the sort the key is of, a cursor is only valid with the same sort.
+type Cursor struct {
+	Sort   ListSort  `json:"s"`
+	Key    string    `json:"k"`
+	ID     uuid.UUID `json:"id"`
+	Pinned bool      `json:"p,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +11,7 @@ type Plan struct {
 	ID          uuid.UUID  `json:"id,omitempty"`
 	Title       *string    `json:"title,omitempty"`
 	Type        *string    `json:"type,omitempty"`
+	Status      *string    `json:"status,omitempty"`
 	SortOrder   int        `json:"sortOrder,omitempty" db:"sort_order"`
 	Starts      *time.Time `json:"starts,omitempty"`
 	Ends        *time.Time `json:"ends,omitempty"`
@@ -18,7 +20,9 @@ type Plan struct {
 	UpdatedAt   *time.Time `json:"updatedAt,omitempty" db:"updated_at"`
 	Members     []User     `json:"members,omitempty" db:"user_id"`
 	IsShared    bool       `json:"isShared,omitempty" db:"is_shared"`
+	IsPinned    bool       `json:"isPinned,omitempty" db:"is_pinned"`
 	User        User       `json:"user,omitempty" db:"user"`
+	CursorKey   string     `json:"-" db:"cursor_key"`
 }
 
 type PlanIn struct {
@@ -35,7 +39,43 @@ const (
 	PlanTypeArchived PlanType = "Archived"
 )
 
+// AllPlanTypes are the built-in plan types, users may add their own as plan categories
 var AllPlanTypes = []PlanType{
 	PlanTypeMain,
 	PlanTypeArchived,
 }
+
+// BuiltInPlanType returns the built-in type matching t regardless of case
+func BuiltInPlanType(t string) (PlanType, bool) {
+	for _, pt := range AllPlanTypes {
+		if strings.EqualFold(t, string(pt)) {
+			return pt, true
+		}
+	}
+	return "", false
+}
+
+type PlanStatus string
+
+const (
+	PlanStatusOpen      PlanStatus = "Open"
+	PlanStatusCompleted PlanStatus = "Completed"
+	PlanStatusArchived  PlanStatus = "Archived"
+)
+
+// PlanStatusTransitions lists the statuses each status can move to
+var PlanStatusTransitions = map[PlanStatus][]PlanStatus{
+	PlanStatusOpen:      {PlanStatusCompleted, PlanStatusArchived},
+	PlanStatusCompleted: {PlanStatusOpen, PlanStatusArchived},
+	PlanStatusArchived:  {PlanStatusOpen},
+}
+
+type PlanCategory struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"userId" db:"user_id"`
+	Name      string     `json:"name" db:"name"`
+	SortOrder int        `json:"sortOrder" db:"sort_order"`
+	IsBuiltIn bool       `json:"isBuiltIn" db:"is_built_in"`
+	CreatedAt *time.Time `json:"createdAt,omitempty" db:"created_at"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" db:"updated_at"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// Preferences are the user's settings, defaults apply until the user changes them
+type Preferences struct {
+	UserID             uuid.UUID  `json:"-" db:"user_id"`
+	Timezone           string     `json:"timezone" db:"timezone"`
+	Locale             string     `json:"locale" db:"locale"`
+	WeekStart          string     `json:"weekStart" db:"week_start"`
+	DefaultPlanType    string     `json:"defaultPlanType" db:"default_plan_type"`
+	NotifyPlanShared   bool       `json:"notifyPlanShared" db:"notify_plan_shared"`
+	NotifyTakeoutReady bool       `json:"notifyTakeoutReady" db:"notify_takeout_ready"`
+	Theme              string     `json:"theme" db:"theme"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty" db:"updated_at"`
+}
+
+// PreferencesIn changes only the preferences it has
+type PreferencesIn struct {
+	Timezone           *string `json:"timezone" validate:"omitempty,max=64"`
+	Locale             *string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	WeekStart          *string `json:"weekStart" validate:"omitempty,oneof=monday sunday saturday"`
+	DefaultPlanType    *string `json:"defaultPlanType" validate:"omitempty,max=50"`
+	NotifyPlanShared   *bool   `json:"notifyPlanShared"`
+	NotifyTakeoutReady *bool   `json:"notifyTakeoutReady"`
+	Theme              *string `json:"theme" validate:"omitempty,oneof=system light dark"`
+}
+
+// DefaultPreferences are the preferences of users who did not change any
+func DefaultPreferences(userID uuid.UUID, locale string) Preferences {
+	if locale == "" {
+		locale = "en"
+	}
+	return Preferences{
+		UserID:             userID,
+		Timezone:           "UTC",
+		Locale:             locale,
+		WeekStart:          WeekStartMonday,
+		DefaultPlanType:    string(PlanTypeMain),
+		NotifyPlanShared:   true,
+		NotifyTakeoutReady: true,
+		Theme:              ThemeSystem,
+	}
+}
+
+// Location is the user's timezone, UTC when it is not known
+func (p Preferences) Location() *time.Location {
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Today is the current date in the user's timezone
+func (p Preferences) Today() time.Time {
+	now := time.Now().In(p.Location())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// WeekStartDate is the first day of the current week in the user's timezone and week start
+func (p Preferences) WeekStartDate() time.Time {
+	first := map[string]time.Weekday{WeekStartMonday: time.Monday, WeekStartSunday: time.Sunday, WeekStartSaturday: time.Saturday}[p.WeekStart]
+	today := p.Today()
+	return today.AddDate(0, 0, -((int(today.Weekday()) - int(first) + 7) % 7))
+}
@@ -0,0 +1,40 @@
+package models
+
+import "github.com/google/uuid"
+
+type PlanSearchHit struct {
+	PlanID   uuid.UUID `db:"plan_id"`
+	Title    *string   `db:"title"`
+	Type     string    `db:"type"`
+	IsShared bool      `db:"is_shared"`
+	Snippet  string    `db:"snippet"`
+	Rank     float64   `db:"rank"`
+}
+
+type TaskSearchHit struct {
+	TaskID    uuid.UUID `db:"task_id"`
+	PlanID    uuid.UUID `db:"plan_id"`
+	PlanTitle *string   `db:"plan_title"`
+	PlanType  string    `db:"plan_type"`
+	IsShared  bool      `db:"is_shared"`
+	Done      bool      `db:"done"`
+	Snippet   string    `db:"snippet"`
+	Rank      float64   `db:"rank"`
+}
+
+type SearchResult struct {
+	PlanID   uuid.UUID       `json:"planId"`
+	Title    *string         `json:"title,omitempty"`
+	Type     string          `json:"type"`
+	IsShared bool            `json:"isShared"`
+	Snippet  *string         `json:"snippet,omitempty"`
+	Rank     float64         `json:"rank"`
+	Tasks    []TaskSearchOut `json:"tasks"`
+}
+
+type TaskSearchOut struct {
+	ID      uuid.UUID `json:"id"`
+	Done    bool      `json:"done"`
+	Snippet string    `json:"snippet"`
+	Rank    float64   `json:"rank"`
+}
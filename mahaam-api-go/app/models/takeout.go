@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TakeoutStatus string
+
+const (
+	TakeoutStatusPending TakeoutStatus = "Pending"
+	TakeoutStatusRunning TakeoutStatus = "Running"
+	TakeoutStatusDone    TakeoutStatus = "Done"
+	TakeoutStatusFailed  TakeoutStatus = "Failed"
+)
+
+// TakeoutJob is a request for a zip of all the user's data, the download url is signed and short lived
+type TakeoutJob struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	UserID      uuid.UUID     `json:"-" db:"user_id"`
+	Status      TakeoutStatus `json:"status" db:"status"`
+	Error       *string       `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time     `json:"createdAt" db:"created_at"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty" db:"completed_at"`
+	ExpiresAt   *time.Time    `json:"expiresAt,omitempty" db:"expires_at"`
+	DownloadURL *string       `json:"downloadUrl,omitempty" db:"-"`
+}
+
+type TakeoutProfile struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Email     *string    `json:"email" db:"email"`
+	Name      *string    `json:"name" db:"name"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt *time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type TakeoutPlan struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Title       *string    `json:"title" db:"title"`
+	Type        string     `json:"type" db:"type"`
+	Status      string     `json:"status" db:"status"`
+	Starts      *time.Time `json:"starts" db:"starts"`
+	Ends        *time.Time `json:"ends" db:"ends"`
+	DonePercent *string    `json:"donePercent" db:"done_percent"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt   *time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// TakeoutMembership is a plan of another user shared with the user
+type TakeoutMembership struct {
+	PlanID     uuid.UUID `json:"planId" db:"plan_id"`
+	PlanTitle  *string   `json:"planTitle" db:"plan_title"`
+	OwnerEmail *string   `json:"ownerEmail" db:"owner_email"`
+	Type       string    `json:"type" db:"type"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+type TakeoutTask struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	PlanID    uuid.UUID  `json:"planId" db:"plan_id"`
+	Title     string     `json:"title" db:"title"`
+	Done      bool       `json:"done" db:"done"`
+	Due       *time.Time `json:"due" db:"due"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt *time.Time `json:"updatedAt" db:"updated_at"`
+}
@@ -11,7 +11,9 @@ type Task struct {
 	PlanID    uuid.UUID  `db:"plan_id"`
 	Title     string     `db:"title"`
 	Done      bool       `db:"done"`
+	Due       *time.Time `db:"due"`
 	SortOrder int        `db:"sort_order"`
 	CreatedAt *time.Time `db:"created_at"`
 	UpdatedAt *time.Time `db:"updated_at"`
+	CursorKey string     `json:"-" db:"cursor_key"`
 }
@@ -7,42 +7,124 @@ import (
 )
 
 type User struct {
-	ID    uuid.UUID `json:"id,omitempty"`
-	Email *string   `json:"email,omitempty"`
-	Name  *string   `json:"name,omitempty"`
+	ID        uuid.UUID  `json:"id,omitempty"`
+	Email     *string    `json:"email,omitempty"`
+	Name      *string    `json:"name,omitempty"`
+	DeletesAt *time.Time `json:"deletesAt,omitempty" db:"deletes_at"`
 }
 
 type Device struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	UserID      uuid.UUID `json:"userId" db:"user_id"`
-	Platform    string    `json:"platform" db:"platform"`
-	Fingerprint string    `json:"fingerprint" db:"fingerprint"`
-	Info        string    `json:"info" db:"info"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"userId" db:"user_id"`
+	Platform    string     `json:"platform" db:"platform"`
+	Fingerprint string     `json:"fingerprint" db:"fingerprint"`
+	Info        string     `json:"info" db:"info"`
+	Name        *string    `json:"name,omitempty" db:"name"`
+	LastSeenAt  *time.Time `json:"lastSeenAt,omitempty" db:"last_seen_at"`
+	LastIP      *string    `json:"lastIp,omitempty" db:"last_ip"`
+	Current     bool       `json:"current" db:"-"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+
+	AttestationProvider *string `json:"attestationProvider,omitempty" db:"attestation_provider"`
+	AttestationVerdict  *string `json:"attestationVerdict,omitempty" db:"attestation_verdict"`
 }
 
+// SuggestedEmail is a contact of the user, added when sharing plans with each other.
+// Name is the contact's own user name when they have an account.
 type SuggestedEmail struct {
-	ID        uuid.UUID  `json:"id"`
-	UserID    uuid.UUID  `json:"userId"`
-	Email     *string    `json:"email"`
-	CreatedAt *time.Time `json:"created_at"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"userId" db:"user_id"`
+	Email        *string    `json:"email" db:"email"`
+	Name         *string    `json:"name,omitempty" db:"name"`
+	ShareCount   int        `json:"shareCount" db:"share_count"`
+	LastSharedAt *time.Time `json:"lastSharedAt,omitempty" db:"last_shared_at"`
+	CreatedAt    *time.Time `json:"created_at" db:"created_at"`
 }
 
 type VerifiedUser struct {
 	UserID       uuid.UUID `json:"userId"`
 	DeviceID     uuid.UUID `json:"deviceId"`
 	Jwt          string    `json:"jwt"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
 	UserFullName *string   `json:"userFullName"`
 	Email        *string   `json:"email"`
 }
 
 type CreatedUser struct {
-	ID       uuid.UUID `json:"id"`
-	DeviceID uuid.UUID `json:"deviceId"`
-	Jwt      string    `json:"jwt"`
+	ID           uuid.UUID `json:"id"`
+	DeviceID     uuid.UUID `json:"deviceId"`
+	Jwt          string    `json:"jwt"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+}
+
+// EmailChange holds the otp sids sent to the current and the new email, both otps confirm the change
+type EmailChange struct {
+	OldEmailSid string `json:"oldEmailSid"`
+	NewEmailSid string `json:"newEmailSid"`
+}
+
+// AccountDeletion tells when a requested account deletion happens, logging in again before that cancels it
+type AccountDeletion struct {
+	DeletesAt time.Time `json:"deletesAt"`
 }
 
 type Meta struct {
 	UserID   uuid.UUID
 	DeviceID uuid.UUID
 }
+
+// Otp is a one time password sent by the local otp provider, the code is kept hashed
+type Otp struct {
+	Sid        uuid.UUID  `db:"sid"`
+	Email      string     `db:"email"`
+	CodeHash   string     `db:"code_hash"`
+	Attempts   int        `db:"attempts"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	ApprovedAt *time.Time `db:"approved_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// OidcLogin is a pending OpenID Connect login of a device, the state is kept hashed and the login is used once
+type OidcLogin struct {
+	StateHash    string    `db:"state_hash"`
+	Provider     string    `db:"provider"`
+	DeviceID     uuid.UUID `db:"device_id"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	CreatedAt    time.Time `db:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// OidcAuthorization is where the app sends the user to log in with the identity provider
+type OidcAuthorization struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+}
+
+// AuthAttempt counts attempts of a key, like an email or a device, the key is locked until LockedUntil
+type AuthAttempt struct {
+	Key           string     `db:"key"`
+	Attempts      int        `db:"attempts"`
+	LastAttemptAt time.Time  `db:"last_attempt_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+}
+
+// RateLimitHit counts the hits of a key, like a user's invites, in its current window
+type RateLimitHit struct {
+	Key             string    `db:"key"`
+	Hits            int       `db:"hits"`
+	WindowStartedAt time.Time `db:"window_started_at"`
+}
+
+// RefreshToken is an opaque token of a device session, kept hashed. Each use rotates it to a new one of the same family,
+// using a rotated token again revokes the whole family.
+type RefreshToken struct {
+	ID        uuid.UUID  `db:"id"`
+	DeviceID  uuid.UUID  `db:"device_id"`
+	FamilyID  uuid.UUID  `db:"family_id"`
+	TokenHash string     `db:"token_hash"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
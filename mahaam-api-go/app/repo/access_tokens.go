@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type AccessTokenRepo interface {
+	Create(token *AccessToken, tokenHash string) int64
+	GetMany(userID uuid.UUID) []AccessToken
+	GetCount(userID uuid.UUID) int64
+	GetByHash(tokenHash string) *AccessToken
+	UpdateLastUsed(id uuid.UUID, since time.Duration) int64
+	Delete(userID, id uuid.UUID) int64
+	DeleteByUser(userID uuid.UUID) int64
+}
+
+type accessTokenRepo struct {
+	db *AppDB
+}
+
+func NewAccessTokenRepo(db *AppDB) AccessTokenRepo {
+	return &accessTokenRepo{db: db}
+}
+
+func (r *accessTokenRepo) Create(token *AccessToken, tokenHash string) int64 {
+	query := `
+		INSERT INTO access_tokens (id, user_id, name, scopes, token_hash, created_at, expires_at)
+		VALUES (:id, :user_id, :name, :scopes, :token_hash, :created_at, :expires_at)`
+	params := Param{"id": token.ID, "user_id": token.UserID, "name": token.Name, "scopes": pq.Array(token.Scopes),
+		"token_hash": tokenHash, "created_at": token.CreatedAt, "expires_at": token.ExpiresAt}
+	return execute(r.db, query, params)
+}
+
+func (r *accessTokenRepo) GetMany(userID uuid.UUID) []AccessToken {
+	query := `
+		SELECT id, user_id, name, scopes, created_at, expires_at, last_used_at
+		FROM access_tokens WHERE user_id = :user_id ORDER BY created_at DESC`
+	return selectMany[AccessToken](r.db, query, Param{"user_id": userID})
+}
+
+func (r *accessTokenRepo) GetCount(userID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM access_tokens WHERE user_id = :user_id`
+	return selectOne[int64](r.db, query, Param{"user_id": userID})
+}
+
+// GetByHash returns the token only while it is not expired
+func (r *accessTokenRepo) GetByHash(tokenHash string) *AccessToken {
+	query := `
+		SELECT id, user_id, name, scopes, created_at, expires_at, last_used_at
+		FROM access_tokens
+		WHERE token_hash = :token_hash AND (expires_at IS NULL OR expires_at > current_timestamp)`
+	token := selectOne[AccessToken](r.db, query, Param{"token_hash": tokenHash})
+	if token.ID == uuid.Nil {
+		return nil
+	}
+	return &token
+}
+
+// UpdateLastUsed skips tokens used within the given duration, so scripts calling in a loop do not write on each request
+func (r *accessTokenRepo) UpdateLastUsed(id uuid.UUID, since time.Duration) int64 {
+	query := `
+		UPDATE access_tokens SET last_used_at = current_timestamp
+		WHERE id = :id AND (last_used_at IS NULL OR last_used_at < :since)`
+	return execute(r.db, query, Param{"id": id, "since": time.Now().Add(-since)})
+}
+
+func (r *accessTokenRepo) Delete(userID, id uuid.UUID) int64 {
+	query := `DELETE FROM access_tokens WHERE id = :id AND user_id = :user_id`
+	return execute(r.db, query, Param{"id": id, "user_id": userID})
+}
+
+func (r *accessTokenRepo) DeleteByUser(userID uuid.UUID) int64 {
+	query := `DELETE FROM access_tokens WHERE user_id = :user_id`
+	return execute(r.db, query, Param{"user_id": userID})
+}
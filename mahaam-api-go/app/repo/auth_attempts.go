@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"github.com/lib/pq"
+)
+
+type AuthAttemptRepo interface {
+	GetMany(keys []string) []AuthAttempt
+	Take(key string, freeAttempts int, delays []int) *AuthAttempt
+	Delete(keys []string) int64
+}
+
+type authAttemptRepo struct {
+	db *AppDB
+}
+
+func NewAuthAttemptRepo(db *AppDB) AuthAttemptRepo {
+	return &authAttemptRepo{db: db}
+}
+
+// attemptsWindow is how long attempts are remembered after the last one
+const attemptsWindow = `interval '1 day'`
+
+func (r *authAttemptRepo) GetMany(keys []string) []AuthAttempt {
+	query := `
+		SELECT key, attempts, last_attempt_at, locked_until FROM auth_attempts
+		WHERE key = ANY(:keys) AND last_attempt_at > current_timestamp - ` + attemptsWindow
+	return selectMany[AuthAttempt](r.db, query, Param{"keys": pq.Array(keys)})
+}
+
+// Take counts an attempt of the key, starting over when the last one is older than the window, and returns nil
+// when the key is locked. From the free attempts on, each attempt locks the key for the next of the delays, in seconds.
+// Counting and locking is one statement, so of concurrent attempts only the ones before the lock are counted.
+func (r *authAttemptRepo) Take(key string, freeAttempts int, delays []int) *AuthAttempt {
+	next := `(CASE WHEN auth_attempts.last_attempt_at < current_timestamp - ` + attemptsWindow + `
+		THEN 1 ELSE auth_attempts.attempts + 1 END)`
+	query := `
+		INSERT INTO auth_attempts (key, attempts, last_attempt_at, locked_until)
+		VALUES (:key, 1, current_timestamp, ` + lockedUntil("1") + `)
+		ON CONFLICT (key) DO UPDATE SET
+			attempts = ` + next + `,
+			last_attempt_at = current_timestamp,
+			locked_until = ` + lockedUntil(next) + `
+		WHERE auth_attempts.locked_until IS NULL OR auth_attempts.locked_until <= current_timestamp
+		RETURNING key, attempts, last_attempt_at, locked_until`
+	params := Param{"key": key, "free_attempts": freeAttempts, "delays": pq.Array(delays)}
+	attempt := selectOne[AuthAttempt](r.db, query, params)
+	if attempt.Key == "" {
+		return nil
+	}
+	return &attempt
+}
+
+// lockedUntil is when the attempt of the given number locks the key until, null while it is a free one
+func lockedUntil(attempts string) string {
+	return `CASE WHEN ` + attempts + ` >= :free_attempts THEN current_timestamp + make_interval(secs =>
+		(CAST(:delays AS int[]))[LEAST(` + attempts + ` - :free_attempts + 1, cardinality(CAST(:delays AS int[])))]) END`
+}
+
+// Delete resets the keys, and removes keys of any user whose attempts are out of the window
+func (r *authAttemptRepo) Delete(keys []string) int64 {
+	query := `DELETE FROM auth_attempts WHERE key = ANY(:keys) OR last_attempt_at < current_timestamp - ` + attemptsWindow
+	return execute(r.db, query, Param{"keys": pq.Array(keys)})
+}
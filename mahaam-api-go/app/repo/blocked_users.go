@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+)
+
+type BlockedUserRepo interface {
+	Create(userID, blockedUserID uuid.UUID) int64
+	Delete(userID, blockedUserID uuid.UUID) int64
+	GetMany(userID uuid.UUID) []BlockedUser
+	Exists(userID, blockedUserID uuid.UUID) bool
+}
+
+type blockedUserRepo struct {
+	db *AppDB
+}
+
+func NewBlockedUserRepo(db *AppDB) BlockedUserRepo {
+	return &blockedUserRepo{db: db}
+}
+
+func (r *blockedUserRepo) Create(userID, blockedUserID uuid.UUID) int64 {
+	query := `
+		INSERT INTO blocked_users (user_id, blocked_user_id, created_at)
+		VALUES (:user_id, :blocked_user_id, current_timestamp)
+		ON CONFLICT (user_id, blocked_user_id) DO NOTHING`
+	params := Param{"user_id": userID, "blocked_user_id": blockedUserID}
+	return execute(r.db, query, params)
+}
+
+func (r *blockedUserRepo) Delete(userID, blockedUserID uuid.UUID) int64 {
+	query := `DELETE FROM blocked_users WHERE user_id = :user_id AND blocked_user_id = :blocked_user_id`
+	params := Param{"user_id": userID, "blocked_user_id": blockedUserID}
+	return execute(r.db, query, params)
+}
+
+func (r *blockedUserRepo) GetMany(userID uuid.UUID) []BlockedUser {
+	query := `
+		SELECT b.blocked_user_id, u.email, u.name, b.created_at
+		FROM blocked_users b
+		JOIN users u ON u.id = b.blocked_user_id
+		WHERE b.user_id = :user_id
+		ORDER BY b.created_at DESC`
+	return selectMany[BlockedUser](r.db, query, Param{"user_id": userID})
+}
+
+// Exists tells whether userID blocked blockedUserID
+func (r *blockedUserRepo) Exists(userID, blockedUserID uuid.UUID) bool {
+	query := `SELECT EXISTS(SELECT 1 FROM blocked_users WHERE user_id = :user_id AND blocked_user_id = :blocked_user_id)`
+	params := Param{"user_id": userID, "blocked_user_id": blockedUserID}
+	return selectOne[bool](r.db, query, params)
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+)
+
+type CalendarFeedRepo interface {
+	GetOne(userID uuid.UUID) *CalendarFeed
+	GetUserID(tokenHash string) uuid.UUID
+	Upsert(userID uuid.UUID, tokenHash string) int64
+	UpdateLastAccessed(userID uuid.UUID) int64
+	Delete(userID uuid.UUID) int64
+}
+
+type calendarFeedRepo struct {
+	db *AppDB
+}
+
+func NewCalendarFeedRepo(db *AppDB) CalendarFeedRepo {
+	return &calendarFeedRepo{db: db}
+}
+
+func (r *calendarFeedRepo) GetOne(userID uuid.UUID) *CalendarFeed {
+	query := `SELECT user_id, created_at, last_accessed_at FROM calendar_feeds WHERE user_id = :user_id`
+	feed := selectOne[CalendarFeed](r.db, query, Param{"user_id": userID})
+	if feed.UserID == uuid.Nil {
+		return nil
+	}
+	return &feed
+}
+
+func (r *calendarFeedRepo) GetUserID(tokenHash string) uuid.UUID {
+	query := `SELECT user_id FROM calendar_feeds WHERE token_hash = :token_hash`
+	return selectOne[uuid.UUID](r.db, query, Param{"token_hash": tokenHash})
+}
+
+// Upsert creates the user's feed or replaces its token, so the old url stops working
+func (r *calendarFeedRepo) Upsert(userID uuid.UUID, tokenHash string) int64 {
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash, created_at)
+		VALUES (:user_id, :token_hash, current_timestamp)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = :token_hash, created_at = current_timestamp, last_accessed_at = NULL`
+	params := Param{"user_id": userID, "token_hash": tokenHash}
+	return execute(r.db, query, params)
+}
+
+func (r *calendarFeedRepo) UpdateLastAccessed(userID uuid.UUID) int64 {
+	query := `UPDATE calendar_feeds SET last_accessed_at = current_timestamp WHERE user_id = :user_id`
+	return execute(r.db, query, Param{"user_id": userID})
+}
+
+func (r *calendarFeedRepo) Delete(userID uuid.UUID) int64 {
+	query := `DELETE FROM calendar_feeds WHERE user_id = :user_id`
+	return execute(r.db, query, Param{"user_id": userID})
+}
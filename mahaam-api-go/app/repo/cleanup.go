@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"mahaam-api/app/models"
+)
+
+type CleanupRepo interface {
+	TryLock(key int64) (unlock func(), ok bool)
+	CountAbandonedUsers(inactiveSince time.Time) int64
+	DeleteAbandonedUsers(inactiveSince time.Time, limit int) int64
+	CreateRun(run *models.CleanupRun) int64
+}
+
+type cleanupRepo struct {
+	db *AppDB
+}
+
+func NewCleanupRepo(db *AppDB) CleanupRepo {
+	return &cleanupRepo{db: db}
+}
+
+// TryLock takes a session advisory lock on a connection of its own, so only one node holds it until unlock is called
+func (r *cleanupRepo) TryLock(key int64) (func(), bool) {
+	ctx := context.Background()
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		panic(models.ServerError(err.Error()))
+	}
+	var locked bool
+	if err := conn.GetContext(ctx, &locked, `SELECT pg_try_advisory_lock($1)`, key); err != nil || !locked {
+		conn.Close()
+		return nil, false
+	}
+	return func() {
+		conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, key)
+		conn.Close()
+	}, true
+}
+
+// abandonedUsers are anonymous users with no activity since :inactive_since on their account, devices, plans, tasks
+// or access tokens, and no part in shared plans
+const abandonedUsers = `
+	SELECT u.id FROM users u
+	WHERE u.email IS NULL
+		AND COALESCE(u.updated_at, u.created_at) < :inactive_since
+		AND NOT EXISTS (SELECT 1 FROM devices d WHERE d.user_id = u.id
+			AND GREATEST(d.created_at, d.updated_at, d.last_seen_at) >= :inactive_since)
+		AND NOT EXISTS (SELECT 1 FROM plans p WHERE p.user_id = u.id
+			AND (GREATEST(p.created_at, p.updated_at) >= :inactive_since
+				OR EXISTS (SELECT 1 FROM plan_members m WHERE m.plan_id = p.id)
+				OR EXISTS (SELECT 1 FROM tasks t WHERE t.plan_id = p.id AND GREATEST(t.created_at, t.updated_at) >= :inactive_since)))
+		AND NOT EXISTS (SELECT 1 FROM plan_members m WHERE m.user_id = u.id)
+		AND NOT EXISTS (SELECT 1 FROM access_tokens a WHERE a.user_id = u.id
+			AND GREATEST(a.created_at, a.last_used_at) >= :inactive_since)`
+
+func (r *cleanupRepo) CountAbandonedUsers(inactiveSince time.Time) int64 {
+	query := `SELECT COUNT(1) FROM (` + abandonedUsers + `) abandoned`
+	return selectOne[int64](r.db, query, Param{"inactive_since": inactiveSince})
+}
+
+// DeleteAbandonedUsers checks the conditions again while deleting, so users active meanwhile are kept.
+// Their devices, plans, tasks and the rest are deleted with them.
+func (r *cleanupRepo) DeleteAbandonedUsers(inactiveSince time.Time, limit int) int64 {
+	query := `DELETE FROM users WHERE id IN (` + abandonedUsers + ` LIMIT :limit FOR UPDATE OF u SKIP LOCKED)`
+	return execute(r.db, query, Param{"inactive_since": inactiveSince, "limit": limit})
+}
+
+func (r *cleanupRepo) CreateRun(run *models.CleanupRun) int64 {
+	query := `
+		INSERT INTO monitor.cleanup_runs (id, health_id, dry_run, inactive_days, candidates, deleted, started_at, finished_at)
+		VALUES (:id, :health_id, :dry_run, :inactive_days, :candidates, :deleted, :started_at, :finished_at)`
+	return execute(r.db, query, run)
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+)
+
+type ContactGroupRepo interface {
+	Create(userID uuid.UUID, name string) uuid.UUID
+	UpdateName(id uuid.UUID, name string) int64
+	Delete(id uuid.UUID) int64
+	GetOne(id uuid.UUID) *ContactGroup
+	GetMany(userID uuid.UUID) []ContactGroup
+	GetCount(userID uuid.UUID) int64
+	GetMembers(userID uuid.UUID) []ContactGroupMember
+	GetGroupMembers(groupID uuid.UUID) []ContactGroupMember
+	GetMembersCount(groupID uuid.UUID) int64
+	AddMember(groupID, contactID uuid.UUID) int64
+	RemoveMember(groupID, contactID uuid.UUID) int64
+}
+
+type contactGroupRepo struct {
+	db *AppDB
+}
+
+func NewContactGroupRepo(db *AppDB) ContactGroupRepo {
+	return &contactGroupRepo{db: db}
+}
+
+// Create returns uuid.Nil when the user has a group with the same name
+func (r *contactGroupRepo) Create(userID uuid.UUID, name string) uuid.UUID {
+	query := `
+		INSERT INTO contact_groups (id, user_id, name, created_at)
+		VALUES (:id, :user_id, :name, current_timestamp)
+		ON CONFLICT (user_id, name) DO NOTHING
+		RETURNING id`
+	params := Param{"id": uuid.New(), "user_id": userID, "name": name}
+	return selectOne[uuid.UUID](r.db, query, params)
+}
+
+// UpdateName returns 0 when another group of the user has the name
+func (r *contactGroupRepo) UpdateName(id uuid.UUID, name string) int64 {
+	query := `
+		UPDATE contact_groups g SET name = :name, updated_at = current_timestamp
+		WHERE g.id = :id AND NOT EXISTS (
+			SELECT 1 FROM contact_groups o WHERE o.user_id = g.user_id AND o.name = :name AND o.id != g.id)`
+	params := Param{"id": id, "name": name}
+	return execute(r.db, query, params)
+}
+
+func (r *contactGroupRepo) Delete(id uuid.UUID) int64 {
+	query := `DELETE FROM contact_groups WHERE id = :id`
+	return execute(r.db, query, Param{"id": id})
+}
+
+func (r *contactGroupRepo) GetOne(id uuid.UUID) *ContactGroup {
+	query := `SELECT id, user_id, name, created_at FROM contact_groups WHERE id = :id`
+	group := selectOne[ContactGroup](r.db, query, Param{"id": id})
+	if group.ID == uuid.Nil {
+		return nil
+	}
+	return &group
+}
+
+func (r *contactGroupRepo) GetMany(userID uuid.UUID) []ContactGroup {
+	query := `
+		SELECT id, user_id, name, created_at
+		FROM contact_groups
+		WHERE user_id = :user_id
+		ORDER BY name`
+	return selectMany[ContactGroup](r.db, query, Param{"user_id": userID})
+}
+
+func (r *contactGroupRepo) GetCount(userID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM contact_groups WHERE user_id = :user_id`
+	return selectOne[int64](r.db, query, Param{"user_id": userID})
+}
+
+// GetMembers returns the contacts of all groups of the user, ranked like the contacts list
+func (r *contactGroupRepo) GetMembers(userID uuid.UUID) []ContactGroupMember {
+	query := `
+		SELECT m.group_id, s.id, s.user_id, s.email, u.name, s.share_count, s.last_shared_at, s.created_at
+		FROM contact_group_members m
+		JOIN contact_groups g ON g.id = m.group_id
+		JOIN suggested_emails s ON s.id = m.contact_id
+		LEFT JOIN users u ON u.email = s.email
+		WHERE g.user_id = :user_id
+		ORDER BY ` + contactRank + `, s.created_at DESC`
+	return selectMany[ContactGroupMember](r.db, query, Param{"user_id": userID})
+}
+
+// GetGroupMembers returns the contacts of the group, ranked like the contacts list
+func (r *contactGroupRepo) GetGroupMembers(groupID uuid.UUID) []ContactGroupMember {
+	query := `
+		SELECT m.group_id, s.id, s.user_id, s.email, u.name, s.share_count, s.last_shared_at, s.created_at
+		FROM contact_group_members m
+		JOIN suggested_emails s ON s.id = m.contact_id
+		LEFT JOIN users u ON u.email = s.email
+		WHERE m.group_id = :group_id
+		ORDER BY ` + contactRank + `, s.created_at DESC`
+	return selectMany[ContactGroupMember](r.db, query, Param{"group_id": groupID})
+}
+
+func (r *contactGroupRepo) GetMembersCount(groupID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM contact_group_members WHERE group_id = :group_id`
+	return selectOne[int64](r.db, query, Param{"group_id": groupID})
+}
+
+func (r *contactGroupRepo) AddMember(groupID, contactID uuid.UUID) int64 {
+	query := `
+		INSERT INTO contact_group_members (group_id, contact_id, created_at)
+		VALUES (:group_id, :contact_id, current_timestamp)
+		ON CONFLICT (group_id, contact_id) DO NOTHING`
+	params := Param{"group_id": groupID, "contact_id": contactID}
+	return execute(r.db, query, params)
+}
+
+func (r *contactGroupRepo) RemoveMember(groupID, contactID uuid.UUID) int64 {
+	query := `DELETE FROM contact_group_members WHERE group_id = :group_id AND contact_id = :contact_id`
+	params := Param{"group_id": groupID, "contact_id": contactID}
+	return execute(r.db, query, params)
+}
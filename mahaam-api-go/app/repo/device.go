@@ -2,6 +2,7 @@ package repo
 
 import (
 	"mahaam-api/app/models"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -15,6 +16,8 @@ type DeviceRepo interface {
 	DeleteByUser(userID, exceptDeviceID uuid.UUID) int64
 	DeleteByFingerprint(tx *sqlx.Tx, fingerprint string) int64
 	UpdateUserID(tx *sqlx.Tx, deviceID, userID uuid.UUID) int64
+	UpdateName(userID, deviceID uuid.UUID, name string) int64
+	UpdateLastSeen(deviceID uuid.UUID, ip string, since time.Duration) int64
 }
 
 type deviceRepo struct {
@@ -30,21 +33,22 @@ func (r *deviceRepo) GetDB() *AppDB {
 }
 
 func (r *deviceRepo) GetOne(id uuid.UUID) *Device {
-	query := `SELECT id, user_id, platform, fingerprint, info, created_at FROM devices WHERE id = :id`
+	query := `SELECT id, user_id, platform, fingerprint, info, name, last_seen_at, last_ip, attestation_provider, attestation_verdict, created_at FROM devices WHERE id = :id`
 	dev := selectOne[Device](r.db, query, Param{"id": id})
 	return &dev
 }
 
 func (r *deviceRepo) GetMany(userID uuid.UUID) []Device {
-	query := `SELECT id, user_id, platform, fingerprint, info, created_at 
-			FROM devices WHERE user_id = :user_id ORDER BY created_at DESC`
+	query := `SELECT id, user_id, platform, fingerprint, info, name, last_seen_at, last_ip, attestation_provider, attestation_verdict, created_at
+			FROM devices WHERE user_id = :user_id ORDER BY COALESCE(last_seen_at, created_at) DESC`
 	return selectMany[Device](r.db, query, Param{"user_id": userID})
 }
 
 func (r *deviceRepo) Create(tx *sqlx.Tx, device Device) uuid.UUID {
 	query := `
-		INSERT INTO devices (id, user_id, platform, fingerprint, info, created_at) 
-		VALUES (:id, :user_id, :platform, :fingerprint, :info, current_timestamp)`
+		INSERT INTO devices (id, user_id, platform, fingerprint, info, attestation_provider, attestation_verdict, attested_at, created_at)
+		VALUES (:id, :user_id, :platform, :fingerprint, :info, :attestation_provider, :attestation_verdict,
+			CASE WHEN CAST(:attestation_verdict AS varchar) IS NULL THEN NULL ELSE current_timestamp END, current_timestamp)`
 	device.ID = uuid.New()
 	rows := executeTransaction(tx, query, device)
 	if rows != 1 {
@@ -76,3 +80,18 @@ func (r *deviceRepo) UpdateUserID(tx *sqlx.Tx, deviceId, userId uuid.UUID) int64
 	params := Param{"user_id": userId, "device_id": deviceId}
 	return executeTransaction(tx, query, params)
 }
+
+func (r *deviceRepo) UpdateName(userID, deviceID uuid.UUID, name string) int64 {
+	query := "UPDATE devices SET name = :name, updated_at = current_timestamp WHERE id = :device_id AND user_id = :user_id"
+	params := Param{"name": name, "device_id": deviceID, "user_id": userID}
+	return execute(r.db, query, params)
+}
+
+// UpdateLastSeen skips devices seen from the same ip within the given duration, so active devices do not write on each request
+func (r *deviceRepo) UpdateLastSeen(deviceID uuid.UUID, ip string, since time.Duration) int64 {
+	query := `
+		UPDATE devices SET last_seen_at = current_timestamp, last_ip = :ip
+		WHERE id = :device_id AND (last_seen_at IS NULL OR last_seen_at < :since OR last_ip IS DISTINCT FROM :ip)`
+	params := Param{"device_id": deviceID, "ip": ip, "since": time.Now().Add(-since)}
+	return execute(r.db, query, params)
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type OidcRepo interface {
+	CreateLogin(login *OidcLogin) int64
+	TakeLogin(stateHash string) *OidcLogin
+	GetIdentityUserID(provider, subject string) uuid.UUID
+	CreateIdentity(tx *sqlx.Tx, provider, subject string, userID uuid.UUID, email string) int64
+}
+
+type oidcRepo struct {
+	db *AppDB
+}
+
+func NewOidcRepo(db *AppDB) OidcRepo {
+	return &oidcRepo{db: db}
+}
+
+// CreateLogin keeps one pending login per device, starting a new one drops the previous and expired logins of any device
+func (r *oidcRepo) CreateLogin(login *OidcLogin) int64 {
+	query := `
+		WITH previous AS (DELETE FROM oidc_logins WHERE device_id = :device_id OR expires_at < current_timestamp)
+		INSERT INTO oidc_logins (state_hash, provider, device_id, code_verifier, nonce, created_at, expires_at)
+		VALUES (:state_hash, :provider, :device_id, :code_verifier, :nonce, current_timestamp, :expires_at)`
+	params := Param{"state_hash": login.StateHash, "provider": login.Provider, "device_id": login.DeviceID,
+		"code_verifier": login.CodeVerifier, "nonce": login.Nonce, "expires_at": login.ExpiresAt}
+	return execute(r.db, query, params)
+}
+
+// TakeLogin deletes the login and returns it when it has not expired, so a state is used once
+func (r *oidcRepo) TakeLogin(stateHash string) *OidcLogin {
+	query := `
+		DELETE FROM oidc_logins WHERE state_hash = :state_hash
+		RETURNING state_hash, provider, device_id, code_verifier, nonce, created_at, expires_at`
+	login := selectOne[OidcLogin](r.db, query, Param{"state_hash": stateHash})
+	if login.StateHash == "" || login.ExpiresAt.Before(time.Now()) {
+		return nil
+	}
+	return &login
+}
+
+func (r *oidcRepo) GetIdentityUserID(provider, subject string) uuid.UUID {
+	query := `SELECT user_id FROM user_identities WHERE provider = :provider AND subject = :subject`
+	return selectOne[uuid.UUID](r.db, query, Param{"provider": provider, "subject": subject})
+}
+
+func (r *oidcRepo) CreateIdentity(tx *sqlx.Tx, provider, subject string, userID uuid.UUID, email string) int64 {
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+		VALUES (:provider, :subject, :user_id, :email, current_timestamp)
+		ON CONFLICT (provider, subject) DO UPDATE SET email = :email`
+	params := Param{"provider": provider, "subject": subject, "user_id": userID, "email": email}
+	return executeTransaction(tx, query, params)
+}
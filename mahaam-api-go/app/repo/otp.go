@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OtpRepo interface {
+	Create(sid uuid.UUID, email, codeHash string, expiresAt time.Time) int64
+	GetOne(sid uuid.UUID) *Otp
+	IncrementAttempts(sid uuid.UUID) int
+	Approve(sid uuid.UUID, maxAttempts int) int64
+	DeleteByEmail(email string) int64
+}
+
+type otpRepo struct {
+	db *AppDB
+}
+
+func NewOtpRepo(db *AppDB) OtpRepo {
+	return &otpRepo{db: db}
+}
+
+func (r *otpRepo) Create(sid uuid.UUID, email, codeHash string, expiresAt time.Time) int64 {
+	query := `
+		INSERT INTO otps (sid, email, code_hash, attempts, expires_at, created_at)
+		VALUES (:sid, :email, :code_hash, 0, :expires_at, current_timestamp)`
+	params := Param{"sid": sid, "email": email, "code_hash": codeHash, "expires_at": expiresAt}
+	return execute(r.db, query, params)
+}
+
+func (r *otpRepo) GetOne(sid uuid.UUID) *Otp {
+	query := `SELECT sid, email, code_hash, attempts, expires_at, approved_at, created_at FROM otps WHERE sid = :sid`
+	otp := selectOne[Otp](r.db, query, Param{"sid": sid})
+	if otp.Sid == uuid.Nil {
+		return nil
+	}
+	return &otp
+}
+
+// IncrementAttempts counts an attempt and returns the attempts so far, 0 when the otp is not found
+func (r *otpRepo) IncrementAttempts(sid uuid.UUID) int {
+	query := `UPDATE otps SET attempts = attempts + 1 WHERE sid = :sid RETURNING attempts`
+	return selectOne[int](r.db, query, Param{"sid": sid})
+}
+
+// Approve marks the otp used, it returns 0 when the otp was used, expired or locked meanwhile
+func (r *otpRepo) Approve(sid uuid.UUID, maxAttempts int) int64 {
+	query := `
+		UPDATE otps SET approved_at = current_timestamp
+		WHERE sid = :sid AND approved_at IS NULL AND expires_at > current_timestamp AND attempts <= :max_attempts`
+	params := Param{"sid": sid, "max_attempts": maxAttempts}
+	return execute(r.db, query, params)
+}
+
+// DeleteByEmail removes the email's previous otps, and expired otps of any email
+func (r *otpRepo) DeleteByEmail(email string) int64 {
+	query := `DELETE FROM otps WHERE email = :email OR expires_at < current_timestamp - interval '1 day'`
+	return execute(r.db, query, Param{"email": email})
+}
@@ -12,15 +12,23 @@ import (
 type PlanRepo interface {
 	GetOne(id uuid.UUID) *Plan
 	GetMany(userID uuid.UUID, planType string) []Plan
-	Create(tx *sqlx.Tx, userID uuid.UUID, plan PlanIn) uuid.UUID
+	GetPage(userID uuid.UUID, planType string, q ListQuery) models.Page[Plan]
+	Create(tx *sqlx.Tx, userID uuid.UUID, plan PlanIn, planType string) uuid.UUID
 	Update(plan *PlanIn) int64
 	Delete(tx *sqlx.Tx, id uuid.UUID) int64
 	UpdateDonePercent(tx *sqlx.Tx, id uuid.UUID) int64
 	RemoveFromOrder(tx *sqlx.Tx, userID, id uuid.UUID) int64
-	UpdateOrder(userID uuid.UUID, planType string, oldOrder, newOrder int) int64
+	UpdateOrder(tx *sqlx.Tx, userID uuid.UUID, planType string, oldOrder, newOrder int) int64
 	UpdateType(tx *sqlx.Tx, userID, id uuid.UUID, planType string) error
 	GetCount(userID uuid.UUID, planType string) int64
+	GetOwnedCount(userID uuid.UUID) int64
+	RenameType(tx *sqlx.Tx, userID uuid.UUID, oldType, newType string) int64
+	UpdateStatus(tx *sqlx.Tx, id uuid.UUID, status models.PlanStatus) int64
+	SyncStatusWithTasks(tx *sqlx.Tx, id uuid.UUID) int64
+	GetEndedBefore(graceDays int, limit int) []Plan
 	UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64
+	TransferSharedPlans(tx *sqlx.Tx, userID uuid.UUID) int64
+	Search(userID uuid.UUID, tsQuery string, limit int) []PlanSearchHit
 }
 
 type planRepo struct {
@@ -35,20 +43,19 @@ func (r *planRepo) GetDB() *AppDB {
 	return r.db
 }
 
-func (r *planRepo) Create(tx *sqlx.Tx, userID uuid.UUID, plan PlanIn) uuid.UUID {
+func (r *planRepo) Create(tx *sqlx.Tx, userID uuid.UUID, plan PlanIn, planType string) uuid.UUID {
 	id := uuid.New()
 	query := `
 		INSERT INTO plans (id, user_id, title, starts, ends, type, status, done_percent, sort_order, created_at)
-		VALUES (:id, :user_id, :title, :starts, :ends, :type, :status, '0/0', 
-		(SELECT COUNT(1) FROM plans WHERE user_id = :user_id AND type = :type), current_timestamp)`
+		VALUES (:id, :user_id, :title, :starts, :ends, :type, :status, '0/0', ` + userPlansCount + `, current_timestamp)`
 	params := Param{
 		"id":      id,
 		"user_id": userID,
 		"title":   plan.Title,
 		"starts":  plan.Starts,
 		"ends":    plan.Ends,
-		"type":    models.PlanTypeMain,
-		"status":  "Open",
+		"type":    planType,
+		"status":  models.PlanStatusOpen,
 	}
 	executeTransaction(tx, query, params)
 	return id
@@ -61,7 +68,7 @@ func (r *planRepo) Update(plan *PlanIn) int64 {
 
 func (r *planRepo) GetOne(id uuid.UUID) *Plan {
 	query := `
-		SELECT c.id, c.title, c.starts, c.ends, c.type, c.done_percent, c.sort_order,
+		SELECT c.id, c.title, c.starts, c.ends, c.type, c.status, c.done_percent, c.sort_order,
 			EXISTS(SELECT 1 FROM plan_members cm WHERE cm.plan_id = c.id) AS is_shared,
 			u.id "user.id", u.email "user.email", u.name "user.name"
 		FROM plans c
@@ -74,8 +81,9 @@ func (r *planRepo) GetOne(id uuid.UUID) *Plan {
 
 func (r *planRepo) GetMany(userID uuid.UUID, planType string) []Plan {
 	query := `
-		SELECT c.id, c.title, c.starts, c.ends, c.type, c.done_percent, c.sort_order,
+		SELECT c.id, c.title, c.starts, c.ends, c.type, c.status, c.done_percent, c.sort_order,
 			EXISTS(SELECT 1 FROM plan_members cm WHERE cm.plan_id = c.id) AS is_shared,
+			EXISTS(SELECT 1 FROM plan_pins pp WHERE pp.plan_id = c.id AND pp.user_id = :user_id) AS is_pinned,
 			u.id "user.id", u.email "user.email", u.name "user.name"
 		FROM plans c
 		LEFT JOIN users u ON c.user_id = u.id
@@ -86,66 +94,176 @@ func (r *planRepo) GetMany(userID uuid.UUID, planType string) []Plan {
 	return selectMany[Plan](r.db, query, params)
 }
 
+// planOrders sorts owned and shared plans by the user's own order in manual order, with pinned plans first
+var planOrders = map[models.ListSort]listOrder{
+	models.ListSortManual: {key: "coalesce(cm.sort_order, c.sort_order)", keyType: "int",
+		pinned: "EXISTS(SELECT 1 FROM plan_pins pp WHERE pp.plan_id = c.id AND pp.user_id = :user_id)"},
+	models.ListSortCreated: {key: "c.created_at", keyType: "timestamptz"},
+	models.ListSortUpdated: {key: "coalesce(c.updated_at, c.created_at)", keyType: "timestamptz"},
+	models.ListSortDue:     {key: "coalesce(c.ends, DATE 'infinity')", keyType: "date", asc: true},
+}
+
+// GetPage returns owned and shared plans of planType as one sorted list, shared plans typed and ordered by the member
+func (r *planRepo) GetPage(userID uuid.UUID, planType string, q ListQuery) models.Page[Plan] {
+	order := planOrders[q.Sort]
+	query := fmt.Sprintf(`
+		SELECT c.id, c.title, c.starts, c.ends, coalesce(cm.type, c.type) AS type, c.status, c.done_percent,
+			coalesce(cm.sort_order, c.sort_order) AS sort_order, c.created_at, c.updated_at,
+			c.user_id <> :user_id OR EXISTS(SELECT 1 FROM plan_members cm WHERE cm.plan_id = c.id) AS is_shared,
+			EXISTS(SELECT 1 FROM plan_pins pp WHERE pp.plan_id = c.id AND pp.user_id = :user_id) AS is_pinned,
+			u.id "user.id", u.email "user.email", u.name "user.name",
+			CAST(%s AS text) AS cursor_key
+		FROM plans c
+		LEFT JOIN plan_members cm ON cm.plan_id = c.id AND cm.user_id = :user_id
+		LEFT JOIN users u ON c.user_id = u.id
+		WHERE ((c.user_id = :user_id AND c.type = :type) OR cm.type = :type)`, order.key)
+	params := Param{"user_id": userID, "type": planType}
+	if q.Done != nil {
+		query += ` AND (c.done_percent <> '0/0' AND split_part(c.done_percent, '/', 1) = split_part(c.done_percent, '/', 2)) = :done`
+		params["done"] = *q.Done
+	}
+	if q.DueFrom != nil {
+		query += ` AND c.ends >= CAST(:due_from AS date)`
+		params["due_from"] = *q.DueFrom
+	}
+	if q.DueBefore != nil {
+		query += ` AND c.ends < CAST(:due_before AS date)`
+		params["due_before"] = *q.DueBefore
+	}
+	return selectPage(r.db, query, "c.id", order, q, params, func(p Plan) Cursor {
+		return Cursor{Key: p.CursorKey, ID: p.ID, Pinned: order.pinned != "" && p.IsPinned}
+	})
+}
+
 func (r *planRepo) Delete(tx *sqlx.Tx, id uuid.UUID) int64 {
 	query := `DELETE FROM plans WHERE id = :id`
 	return executeTransaction(tx, query, Param{"id": id})
 }
 
-// UpdateDonePercent updates the done percentage for a plan based on tasks
+// UpdateDonePercent updates the done percentage for a plan based on tasks.
+// Tasks are counted in the transaction, so the ones it just created or deleted are counted too.
 func (r *planRepo) UpdateDonePercent(tx *sqlx.Tx, id uuid.UUID) int64 {
-	query := `SELECT COUNT(1) as total, COUNT(CASE WHEN done THEN 1 END) as done FROM tasks WHERE plan_id = :id`
-	params := Param{"id": id}
-	type Result struct {
-		Total int
-		Done  int
-	}
-	result := selectOne[Result](r.db, query, params)
-	donePercent := fmt.Sprintf("%d/%d", result.Done, result.Total)
-	updateQuery := `UPDATE plans SET done_percent = :done_percent WHERE id = :id`
-	params["done_percent"] = donePercent
-	return executeTransaction(tx, updateQuery, params)
+	query := `
+		UPDATE plans SET done_percent = (
+			SELECT COUNT(CASE WHEN done THEN 1 END) || '/' || COUNT(1) FROM tasks WHERE plan_id = :id)
+		WHERE id = :id`
+	return executeTransaction(tx, query, Param{"id": id})
 }
 
-// RemoveFromOrder decrements sort_order for plans after deletion
+// userPlansCount counts the plans listed for :user_id under :type, owned and shared with the user,
+// which share one sort order
+const userPlansCount = `((SELECT COUNT(1) FROM plans WHERE user_id = :user_id AND type = :type)
+	+ (SELECT COUNT(1) FROM plan_members WHERE user_id = :user_id AND type = :type))`
+
+// RemoveFromOrder decrements sort_order for the owner's plans, owned and shared, after the plan leaves the list
 func (r *planRepo) RemoveFromOrder(tx *sqlx.Tx, userID, id uuid.UUID) int64 {
 	query := `
+		WITH removed AS (SELECT type, sort_order FROM plans WHERE id = :id),
+		shared AS (
+			UPDATE plan_members SET sort_order = sort_order - 1
+			WHERE user_id = :user_id
+			AND type = (SELECT type FROM removed)
+			AND sort_order > (SELECT sort_order FROM removed)
+		)
 		UPDATE plans SET sort_order = sort_order - 1
 		WHERE user_id = :user_id
-		AND type = (SELECT type FROM plans WHERE id = :id)
-		AND sort_order > (SELECT sort_order FROM plans WHERE id = :id)`
+		AND type = (SELECT type FROM removed)
+		AND sort_order > (SELECT sort_order FROM removed)`
 	params := Param{"user_id": userID, "id": id}
 	return executeTransaction(tx, query, params)
 }
 
-func (r *planRepo) UpdateOrder(userID uuid.UUID, planType string, oldOrder, newOrder int) int64 {
-	query := `
-		UPDATE plans SET sort_order = 
-			CASE 
+// UpdateOrder moves a plan within the user's list, owned and shared plans alike
+func (r *planRepo) UpdateOrder(tx *sqlx.Tx, userID uuid.UUID, planType string, oldOrder, newOrder int) int64 {
+	newSortOrder := `
+			CASE
 				WHEN sort_order = :oldOrder THEN :newOrder
 				WHEN sort_order > :oldOrder AND sort_order <= :newOrder THEN sort_order - 1
 				WHEN sort_order >= :newOrder AND sort_order < :oldOrder THEN sort_order + 1
 				ELSE sort_order
-			END
+			END`
+	query := `
+		WITH shared AS (
+			UPDATE plan_members SET sort_order = ` + newSortOrder + `
+			WHERE user_id = :user_id AND type = :type
+		)
+		UPDATE plans SET sort_order = ` + newSortOrder + `
 		WHERE user_id = :user_id AND type = :type`
 	params := Param{"user_id": userID, "type": planType, "oldOrder": oldOrder, "newOrder": newOrder}
-	return execute(r.db, query, params)
+	return executeTransaction(tx, query, params)
 }
 
 func (r *planRepo) UpdateType(tx *sqlx.Tx, userID, id uuid.UUID, planType string) error {
-	query := `UPDATE plans SET type = :type, 
-		sort_order = (SELECT COUNT(1) FROM plans WHERE user_id = :user_id AND type = :type), 
+	query := `UPDATE plans SET type = :type,
+		sort_order = ` + userPlansCount + `,
 		updated_at = current_timestamp WHERE id = :id`
 	params := Param{"id": id, "type": planType, "user_id": userID}
 	_, err := tx.NamedExec(query, params)
 	return err
 }
 
+// GetCount counts plans listed for the user under planType, owned and shared
 func (r *planRepo) GetCount(userID uuid.UUID, planType string) int64 {
-	query := `SELECT COUNT(1) FROM plans WHERE user_id = :user_id AND type = :type`
+	query := `SELECT ` + userPlansCount
 	params := Param{"user_id": userID, "type": planType}
 	return selectOne[int64](r.db, query, params)
 }
 
+// GetOwnedCount counts the user's own plans of all types but Archived, without plans shared with the user
+func (r *planRepo) GetOwnedCount(userID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM plans WHERE user_id = :user_id AND type <> :archived`
+	params := Param{"user_id": userID, "archived": models.PlanTypeArchived}
+	return selectOne[int64](r.db, query, params)
+}
+
+// UpdateStatus changes the plan status, returns 0 when it already has that status
+func (r *planRepo) UpdateStatus(tx *sqlx.Tx, id uuid.UUID, status models.PlanStatus) int64 {
+	query := `UPDATE plans SET status = :status, updated_at = current_timestamp WHERE id = :id AND status <> :status`
+	return executeTransaction(tx, query, Param{"id": id, "status": status})
+}
+
+// SyncStatusWithTasks completes an open plan once all its tasks are done, and reopens a completed one otherwise.
+// Archived plans are left as is.
+func (r *planRepo) SyncStatusWithTasks(tx *sqlx.Tx, id uuid.UUID) int64 {
+	query := `
+		UPDATE plans SET status = t.status, updated_at = current_timestamp
+		FROM (
+			SELECT CASE WHEN COUNT(1) > 0 AND COUNT(1) = COUNT(CASE WHEN done THEN 1 END)
+				THEN :completed ELSE :open END AS status
+			FROM tasks WHERE plan_id = :id
+		) t
+		WHERE plans.id = :id AND plans.status IN (:open, :completed) AND plans.status <> t.status`
+	params := Param{"id": id, "open": models.PlanStatusOpen, "completed": models.PlanStatusCompleted}
+	return executeTransaction(tx, query, params)
+}
+
+// GetEndedBefore returns not yet archived plans whose ends date passed by more than graceDays in their owner's timezone
+func (r *planRepo) GetEndedBefore(graceDays int, limit int) []Plan {
+	query := `
+		SELECT c.id, c.title, c.starts, c.ends, c.type, c.status, c.done_percent, c.sort_order,
+			u.id "user.id", u.email "user.email", u.name "user.name"
+		FROM plans c
+		LEFT JOIN users u ON c.user_id = u.id
+		LEFT JOIN user_preferences p ON p.user_id = c.user_id
+		WHERE c.ends < CAST(timezone(COALESCE(p.timezone, 'UTC'), current_timestamp) AS date) - CAST(:grace_days AS int)
+			AND c.status <> :archived
+		ORDER BY c.ends ASC
+		LIMIT :limit`
+	params := Param{"grace_days": graceDays, "archived": models.PlanStatusArchived, "limit": limit}
+	return selectMany[Plan](r.db, query, params)
+}
+
+// RenameType moves all plans of a renamed category to its new name, owned and shared, keeping their order
+func (r *planRepo) RenameType(tx *sqlx.Tx, userID uuid.UUID, oldType, newType string) int64 {
+	query := `
+		WITH shared AS (
+			UPDATE plan_members SET type = :new_type WHERE user_id = :user_id AND type = :old_type
+		)
+		UPDATE plans SET type = :new_type, updated_at = current_timestamp WHERE user_id = :user_id AND type = :old_type`
+	params := Param{"user_id": userID, "old_type": oldType, "new_type": newType}
+	return executeTransaction(tx, query, params)
+}
+
 func (r *planRepo) UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64 {
 	query := `
 		UPDATE plans
@@ -156,3 +274,38 @@ func (r *planRepo) UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int
 	params := Param{"newUserID": newUserID, "oldUserID": oldUserID}
 	return executeTransaction(tx, query, params)
 }
+
+// TransferSharedPlans gives each shared plan of the user to its oldest member,
+// who stops being a member and keeps the plan in the same type and position
+func (r *planRepo) TransferSharedPlans(tx *sqlx.Tx, userID uuid.UUID) int64 {
+	query := `
+		WITH heirs AS (
+			SELECT DISTINCT ON (cm.plan_id) cm.plan_id, cm.user_id, cm.type, cm.sort_order
+			FROM plan_members cm
+			JOIN plans c ON c.id = cm.plan_id
+			WHERE c.user_id = :user_id
+			ORDER BY cm.plan_id, cm.created_at, cm.user_id
+		), removed AS (
+			DELETE FROM plan_members cm USING heirs h
+			WHERE cm.plan_id = h.plan_id AND cm.user_id = h.user_id
+		)
+		UPDATE plans c SET user_id = h.user_id, type = h.type, sort_order = h.sort_order, updated_at = current_timestamp
+		FROM heirs h WHERE c.id = h.plan_id`
+	params := Param{"user_id": userID}
+	return executeTransaction(tx, query, params)
+}
+
+// Search matches plan titles of owned and shared plans against a tsquery, best ranked first
+func (r *planRepo) Search(userID uuid.UUID, tsQuery string, limit int) []PlanSearchHit {
+	query := `
+		SELECT c.id AS plan_id, c.title, c.type, c.user_id <> :user_id AS is_shared,
+			ts_headline('simple', coalesce(c.title, ''), q.query, :headline_options) AS snippet,
+			ts_rank(c.search_vector, q.query) AS rank
+		FROM plans c, to_tsquery('simple', :query) q(query)
+		WHERE c.search_vector @@ q.query
+		AND (c.user_id = :user_id OR c.id IN (SELECT plan_id FROM plan_members WHERE user_id = :user_id))
+		ORDER BY rank DESC
+		LIMIT :limit`
+	params := Param{"user_id": userID, "query": tsQuery, "headline_options": SearchHeadlineOptions, "limit": limit}
+	return selectMany[PlanSearchHit](r.db, query, params)
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PlanCategoryRepo interface {
+	GetOne(id uuid.UUID) *PlanCategory
+	GetOneByName(userID uuid.UUID, name string) *PlanCategory
+	GetMany(userID uuid.UUID) []PlanCategory
+	GetCount(userID uuid.UUID) int64
+	Create(userID uuid.UUID, name string) uuid.UUID
+	UpdateName(tx *sqlx.Tx, id uuid.UUID, name string) int64
+	UpdateOrder(userID uuid.UUID, oldOrder, newOrder int) int64
+	RemoveFromOrder(tx *sqlx.Tx, userID, id uuid.UUID) int64
+	Delete(tx *sqlx.Tx, id uuid.UUID) int64
+	UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64
+}
+
+type planCategoryRepo struct {
+	db *AppDB
+}
+
+func NewPlanCategoryRepo(db *AppDB) PlanCategoryRepo {
+	return &planCategoryRepo{db: db}
+}
+
+func (r *planCategoryRepo) GetOne(id uuid.UUID) *PlanCategory {
+	query := `SELECT id, user_id, name, sort_order, created_at, updated_at FROM plan_categories WHERE id = :id`
+	category := selectOne[PlanCategory](r.db, query, Param{"id": id})
+	if category.ID == uuid.Nil {
+		return nil
+	}
+	return &category
+}
+
+func (r *planCategoryRepo) GetOneByName(userID uuid.UUID, name string) *PlanCategory {
+	query := `
+		SELECT id, user_id, name, sort_order, created_at, updated_at
+		FROM plan_categories WHERE user_id = :user_id AND lower(name) = lower(:name)`
+	category := selectOne[PlanCategory](r.db, query, Param{"user_id": userID, "name": name})
+	if category.ID == uuid.Nil {
+		return nil
+	}
+	return &category
+}
+
+func (r *planCategoryRepo) GetMany(userID uuid.UUID) []PlanCategory {
+	query := `
+		SELECT id, user_id, name, sort_order, created_at, updated_at
+		FROM plan_categories WHERE user_id = :user_id ORDER BY sort_order ASC`
+	return selectMany[PlanCategory](r.db, query, Param{"user_id": userID})
+}
+
+func (r *planCategoryRepo) GetCount(userID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM plan_categories WHERE user_id = :user_id`
+	return selectOne[int64](r.db, query, Param{"user_id": userID})
+}
+
+func (r *planCategoryRepo) Create(userID uuid.UUID, name string) uuid.UUID {
+	id := uuid.New()
+	query := `
+		INSERT INTO plan_categories (id, user_id, name, sort_order, created_at)
+		VALUES (:id, :user_id, :name, (SELECT COUNT(1) FROM plan_categories WHERE user_id = :user_id), current_timestamp)`
+	execute(r.db, query, Param{"id": id, "user_id": userID, "name": name})
+	return id
+}
+
+func (r *planCategoryRepo) UpdateName(tx *sqlx.Tx, id uuid.UUID, name string) int64 {
+	query := `UPDATE plan_categories SET name = :name, updated_at = current_timestamp WHERE id = :id`
+	return executeTransaction(tx, query, Param{"id": id, "name": name})
+}
+
+func (r *planCategoryRepo) UpdateOrder(userID uuid.UUID, oldOrder, newOrder int) int64 {
+	query := `
+		UPDATE plan_categories SET sort_order =
+			CASE
+				WHEN sort_order = :oldOrder THEN :newOrder
+				WHEN sort_order > :oldOrder AND sort_order <= :newOrder THEN sort_order - 1
+				WHEN sort_order >= :newOrder AND sort_order < :oldOrder THEN sort_order + 1
+				ELSE sort_order
+			END
+		WHERE user_id = :user_id`
+	params := Param{"user_id": userID, "oldOrder": oldOrder, "newOrder": newOrder}
+	return execute(r.db, query, params)
+}
+
+// RemoveFromOrder decrements sort_order for categories after deletion
+func (r *planCategoryRepo) RemoveFromOrder(tx *sqlx.Tx, userID, id uuid.UUID) int64 {
+	query := `
+		UPDATE plan_categories SET sort_order = sort_order - 1
+		WHERE user_id = :user_id
+		AND sort_order > (SELECT sort_order FROM plan_categories WHERE id = :id)`
+	return executeTransaction(tx, query, Param{"user_id": userID, "id": id})
+}
+
+func (r *planCategoryRepo) Delete(tx *sqlx.Tx, id uuid.UUID) int64 {
+	query := `DELETE FROM plan_categories WHERE id = :id`
+	return executeTransaction(tx, query, Param{"id": id})
+}
+
+// UpdateUserID moves categories of a merged user, skipping names the new user already has
+// in any letter case. Plans of the skipped categories take the new user's spelling of the name.
+func (r *planCategoryRepo) UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64 {
+	params := Param{"newUserID": newUserID, "oldUserID": oldUserID}
+	executeTransaction(tx, `
+		UPDATE plans c SET type = pc.name, updated_at = current_timestamp
+		FROM plan_categories pc
+		WHERE c.user_id = :oldUserID AND pc.user_id = :newUserID
+		AND lower(c.type) = lower(pc.name) AND c.type != pc.name`, params)
+
+	query := `
+		UPDATE plan_categories
+		SET user_id = :newUserID,
+			sort_order = sort_order + (SELECT COUNT(1) FROM plan_categories WHERE user_id = :newUserID),
+			updated_at = current_timestamp
+		WHERE user_id = :oldUserID
+		AND lower(name) NOT IN (SELECT lower(name) FROM plan_categories WHERE user_id = :newUserID)`
+	return executeTransaction(tx, query, params)
+}
@@ -1,16 +1,23 @@
 package repo
 
 import (
+	"mahaam-api/app/models"
+
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type PlanMembersRepo interface {
 	Create(planID, userID uuid.UUID) int64
-	Delete(planID, userID uuid.UUID) int64
-	GetOtherPlans(userID uuid.UUID) []Plan
+	Delete(tx *sqlx.Tx, planID, userID uuid.UUID) int64
+	GetOtherPlans(userID uuid.UUID, planType string) []Plan
 	GetUsers(planID uuid.UUID) []User
 	GetPlansCount(userID uuid.UUID) int64
 	GetUsersCount(planID uuid.UUID) int64
+	Exists(planID, userID uuid.UUID) bool
+	UpdateType(tx *sqlx.Tx, planID, userID uuid.UUID, planType string) int64
+	RemoveFromOrder(tx *sqlx.Tx, planID, userID uuid.UUID) int64
+	RemoveFromMembersOrder(tx *sqlx.Tx, planID uuid.UUID) int64
 }
 
 type planMembersRepo struct {
@@ -21,32 +28,35 @@ func NewPlanMembersRepo(db *AppDB) PlanMembersRepo {
 	return &planMembersRepo{db: db}
 }
 
+// Create adds the plan to the member's Main list, on top of it
 func (r *planMembersRepo) Create(planID, userID uuid.UUID) int64 {
 	query := `
-		INSERT INTO plan_members (plan_id, user_id, created_at)
-        VALUES (:plan_id, :user_id, current_timestamp)`
-	params := Param{"plan_id": planID, "user_id": userID}
+		INSERT INTO plan_members (plan_id, user_id, type, sort_order, created_at)
+		VALUES (:plan_id, :user_id, :type, ` + userPlansCount + `, current_timestamp)`
+	params := Param{"plan_id": planID, "user_id": userID, "type": models.PlanTypeMain}
 	return execute(r.db, query, params)
 }
 
-func (r *planMembersRepo) Delete(planID, userID uuid.UUID) int64 {
+func (r *planMembersRepo) Delete(tx *sqlx.Tx, planID, userID uuid.UUID) int64 {
 	query := `
 		DELETE FROM plan_members
 		WHERE plan_id = :plan_id AND user_id = :user_id`
 	params := Param{"plan_id": planID, "user_id": userID}
-	return execute(r.db, query, params)
+	return executeTransaction(tx, query, params)
 }
 
-func (r *planMembersRepo) GetOtherPlans(userID uuid.UUID) []Plan {
+// GetOtherPlans returns plans shared with the user under planType, typed and ordered as the member arranged them
+func (r *planMembersRepo) GetOtherPlans(userID uuid.UUID, planType string) []Plan {
 	query := `
-		SELECT c.id, c.title, c.starts, c.ends, c.type, c.done_percent, c.sort_order, 
-			true AS is_shared, u.id as "user.id",u.email as "user.email",u.name as "user.name"
+		SELECT c.id, c.title, c.starts, c.ends, cm.type, c.status, c.done_percent, cm.sort_order, 
+			true AS is_shared, EXISTS(SELECT 1 FROM plan_pins pp WHERE pp.plan_id = c.id AND pp.user_id = :user_id) AS is_pinned,
+			u.id as "user.id",u.email as "user.email",u.name as "user.name"
 		FROM plan_members cm
 		LEFT JOIN plans c ON cm.plan_id = c.id
 		LEFT JOIN users u ON c.user_id = u.id
-		WHERE cm.user_id = :user_id
-		ORDER BY c.sort_order ASC`
-	params := Param{"user_id": userID}
+		WHERE cm.user_id = :user_id AND cm.type = :type
+		ORDER BY cm.sort_order DESC`
+	params := Param{"user_id": userID, "type": planType}
 	return selectMany[Plan](r.db, query, params)
 }
 
@@ -80,3 +90,52 @@ func (r *planMembersRepo) GetUsersCount(planID uuid.UUID) int64 {
 	return selectOne[int64](r.db, query, param)
 
 }
+
+func (r *planMembersRepo) Exists(planID, userID uuid.UUID) bool {
+	query := `SELECT EXISTS(SELECT 1 FROM plan_members WHERE plan_id = :plan_id AND user_id = :user_id)`
+	params := Param{"plan_id": planID, "user_id": userID}
+	return selectOne[bool](r.db, query, params)
+}
+
+// UpdateType moves the shared plan to another list of the member, on top of it
+func (r *planMembersRepo) UpdateType(tx *sqlx.Tx, planID, userID uuid.UUID, planType string) int64 {
+	query := `
+		UPDATE plan_members SET type = :type, sort_order = ` + userPlansCount + `
+		WHERE plan_id = :plan_id AND user_id = :user_id`
+	params := Param{"plan_id": planID, "user_id": userID, "type": planType}
+	return executeTransaction(tx, query, params)
+}
+
+// RemoveFromOrder decrements sort_order for the member's plans, owned and shared, after the shared plan leaves the list
+func (r *planMembersRepo) RemoveFromOrder(tx *sqlx.Tx, planID, userID uuid.UUID) int64 {
+	query := `
+		WITH removed AS (SELECT type, sort_order FROM plan_members WHERE plan_id = :plan_id AND user_id = :user_id),
+		owned AS (
+			UPDATE plans SET sort_order = sort_order - 1
+			WHERE user_id = :user_id
+			AND type = (SELECT type FROM removed)
+			AND sort_order > (SELECT sort_order FROM removed)
+		)
+		UPDATE plan_members SET sort_order = sort_order - 1
+		WHERE user_id = :user_id
+		AND type = (SELECT type FROM removed)
+		AND sort_order > (SELECT sort_order FROM removed)`
+	params := Param{"plan_id": planID, "user_id": userID}
+	return executeTransaction(tx, query, params)
+}
+
+// RemoveFromMembersOrder is RemoveFromOrder for all members of the plan, used before deleting it
+func (r *planMembersRepo) RemoveFromMembersOrder(tx *sqlx.Tx, planID uuid.UUID) int64 {
+	query := `
+		WITH removed AS (SELECT user_id, type, sort_order FROM plan_members WHERE plan_id = :plan_id),
+		owned AS (
+			UPDATE plans c SET sort_order = c.sort_order - 1
+			FROM removed
+			WHERE c.user_id = removed.user_id AND c.type = removed.type AND c.sort_order > removed.sort_order
+		)
+		UPDATE plan_members cm SET sort_order = cm.sort_order - 1
+		FROM removed
+		WHERE cm.user_id = removed.user_id AND cm.type = removed.type AND cm.sort_order > removed.sort_order`
+	params := Param{"plan_id": planID}
+	return executeTransaction(tx, query, params)
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PlanPinsRepo interface {
+	Create(planID, userID uuid.UUID) int64
+	Delete(planID, userID uuid.UUID) int64
+	GetCount(userID uuid.UUID) int64
+	UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64
+}
+
+type planPinsRepo struct {
+	db *AppDB
+}
+
+func NewPlanPinsRepo(db *AppDB) PlanPinsRepo {
+	return &planPinsRepo{db: db}
+}
+
+func (r *planPinsRepo) Create(planID, userID uuid.UUID) int64 {
+	query := `
+		INSERT INTO plan_pins (user_id, plan_id, created_at)
+		VALUES (:user_id, :plan_id, current_timestamp)
+		ON CONFLICT (user_id, plan_id) DO NOTHING`
+	params := Param{"plan_id": planID, "user_id": userID}
+	return execute(r.db, query, params)
+}
+
+func (r *planPinsRepo) Delete(planID, userID uuid.UUID) int64 {
+	query := `DELETE FROM plan_pins WHERE plan_id = :plan_id AND user_id = :user_id`
+	params := Param{"plan_id": planID, "user_id": userID}
+	return execute(r.db, query, params)
+}
+
+func (r *planPinsRepo) GetCount(userID uuid.UUID) int64 {
+	query := `SELECT COUNT(1) FROM plan_pins WHERE user_id = :user_id`
+	return selectOne[int64](r.db, query, Param{"user_id": userID})
+}
+
+// UpdateUserID moves pins of a merged user, skipping plans the new user already pinned
+func (r *planPinsRepo) UpdateUserID(tx *sqlx.Tx, oldUserID, newUserID uuid.UUID) int64 {
+	query := `
+		UPDATE plan_pins SET user_id = :newUserID
+		WHERE user_id = :oldUserID
+		AND plan_id NOT IN (SELECT plan_id FROM plan_pins WHERE user_id = :newUserID)`
+	params := Param{"newUserID": newUserID, "oldUserID": oldUserID}
+	return executeTransaction(tx, query, params)
+}
@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PreferencesRepo interface {
+	GetOne(userID uuid.UUID) *Preferences
+	GetOneByEmail(email string) *Preferences
+	Upsert(p *Preferences) int64
+	UpdateDefaultPlanType(tx *sqlx.Tx, userID uuid.UUID, oldType, newType string) int64
+}
+
+type preferencesRepo struct {
+	db *AppDB
+}
+
+func NewPreferencesRepo(db *AppDB) PreferencesRepo {
+	return &preferencesRepo{db: db}
+}
+
+const preferencesColumns = `p.user_id, p.timezone, p.locale, p.week_start, p.default_plan_type,
+	p.notify_plan_shared, p.notify_takeout_ready, p.theme, p.updated_at`
+
+func (r *preferencesRepo) GetOne(userID uuid.UUID) *Preferences {
+	query := `SELECT ` + preferencesColumns + ` FROM user_preferences p WHERE p.user_id = :user_id`
+	p := selectOne[Preferences](r.db, query, Param{"user_id": userID})
+	if p.UserID == uuid.Nil {
+		return nil
+	}
+	return &p
+}
+
+func (r *preferencesRepo) GetOneByEmail(email string) *Preferences {
+	query := `SELECT ` + preferencesColumns + ` FROM user_preferences p JOIN users u ON u.id = p.user_id WHERE u.email = :email`
+	p := selectOne[Preferences](r.db, query, Param{"email": email})
+	if p.UserID == uuid.Nil {
+		return nil
+	}
+	return &p
+}
+
+func (r *preferencesRepo) Upsert(p *Preferences) int64 {
+	query := `
+		INSERT INTO user_preferences (user_id, timezone, locale, week_start, default_plan_type,
+			notify_plan_shared, notify_takeout_ready, theme, updated_at)
+		VALUES (:user_id, :timezone, :locale, :week_start, :default_plan_type,
+			:notify_plan_shared, :notify_takeout_ready, :theme, current_timestamp)
+		ON CONFLICT (user_id) DO UPDATE SET timezone = :timezone, locale = :locale, week_start = :week_start,
+			default_plan_type = :default_plan_type, notify_plan_shared = :notify_plan_shared,
+			notify_takeout_ready = :notify_takeout_ready, theme = :theme, updated_at = current_timestamp`
+	return execute(r.db, query, p)
+}
+
+// UpdateDefaultPlanType follows a renamed or deleted category of the user's default plan type
+func (r *preferencesRepo) UpdateDefaultPlanType(tx *sqlx.Tx, userID uuid.UUID, oldType, newType string) int64 {
+	query := `
+		UPDATE user_preferences SET default_plan_type = :new_type, updated_at = current_timestamp
+		WHERE user_id = :user_id AND default_plan_type = :old_type`
+	params := Param{"user_id": userID, "old_type": oldType, "new_type": newType}
+	return executeTransaction(tx, query, params)
+}
@@ -0,0 +1,32 @@
+package repo
+
+type RateLimitRepo interface {
+	Hit(key string, windowSeconds int) RateLimitHit
+}
+
+type rateLimitRepo struct {
+	db *AppDB
+}
+
+func NewRateLimitRepo(db *AppDB) RateLimitRepo {
+	return &rateLimitRepo{db: db}
+}
+
+// Hit counts a hit of the key in its window and returns the hits so far, starting a new window when the last one ended.
+// Counting and reading is one statement, so concurrent hits are all counted. Ended windows of other keys are removed.
+func (r *rateLimitRepo) Hit(key string, windowSeconds int) RateLimitHit {
+	query := `
+		WITH expired AS (
+			DELETE FROM rate_limits
+			WHERE key <> :key AND window_started_at < current_timestamp - make_interval(secs => :window_seconds)
+		)
+		INSERT INTO rate_limits (key, hits, window_started_at)
+		VALUES (:key, 1, current_timestamp)
+		ON CONFLICT (key) DO UPDATE SET
+			hits = CASE WHEN rate_limits.window_started_at < current_timestamp - make_interval(secs => :window_seconds)
+				THEN 1 ELSE rate_limits.hits + 1 END,
+			window_started_at = CASE WHEN rate_limits.window_started_at < current_timestamp - make_interval(secs => :window_seconds)
+				THEN current_timestamp ELSE rate_limits.window_started_at END
+		RETURNING key, hits, window_started_at`
+	return selectOne[RateLimitHit](r.db, query, Param{"key": key, "window_seconds": windowSeconds})
+}
@@ -0,0 +1,90 @@
+package repo
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RefreshTokenRepo interface {
+	Create(tx *sqlx.Tx, deviceID, familyID uuid.UUID, tokenHash string, expiresAt time.Time) int64
+	GetByHash(tokenHash string) *RefreshToken
+	Rotate(id uuid.UUID, tokenHash string, expiresAt time.Time) int64
+	RevokeFamily(familyID uuid.UUID) int64
+	RevokeDevice(tx *sqlx.Tx, deviceID uuid.UUID) int64
+	IsSessionActive(deviceID, familyID uuid.UUID) bool
+}
+
+type refreshTokenRepo struct {
+	db *AppDB
+}
+
+func NewRefreshTokenRepo(db *AppDB) RefreshTokenRepo {
+	return &refreshTokenRepo{db: db}
+}
+
+func (r *refreshTokenRepo) Create(tx *sqlx.Tx, deviceID, familyID uuid.UUID, tokenHash string, expiresAt time.Time) int64 {
+	query := `
+		INSERT INTO refresh_tokens (id, device_id, family_id, token_hash, created_at, expires_at)
+		VALUES (:id, :device_id, :family_id, :token_hash, current_timestamp, :expires_at)`
+	params := Param{"id": uuid.New(), "device_id": deviceID, "family_id": familyID, "token_hash": tokenHash, "expires_at": expiresAt}
+	return executeTransaction(tx, query, params)
+}
+
+func (r *refreshTokenRepo) GetByHash(tokenHash string) *RefreshToken {
+	query := `
+		SELECT id, device_id, family_id, token_hash, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = :token_hash`
+	token := selectOne[RefreshToken](r.db, query, Param{"token_hash": tokenHash})
+	if token.ID == uuid.Nil {
+		return nil
+	}
+	return &token
+}
+
+// Rotate marks the token used and adds its successor to the same family in one statement,
+// it returns 0 when the token was used or revoked meanwhile
+func (r *refreshTokenRepo) Rotate(id uuid.UUID, tokenHash string, expiresAt time.Time) int64 {
+	query := `
+		WITH used AS (
+			UPDATE refresh_tokens SET used_at = current_timestamp
+			WHERE id = :id AND used_at IS NULL AND revoked_at IS NULL AND expires_at > current_timestamp
+			RETURNING device_id, family_id
+		)
+		INSERT INTO refresh_tokens (id, device_id, family_id, token_hash, created_at, expires_at)
+		SELECT :new_id, device_id, family_id, :token_hash, current_timestamp, :expires_at FROM used`
+	params := Param{"id": id, "new_id": uuid.New(), "token_hash": tokenHash, "expires_at": expiresAt}
+	return execute(r.db, query, params)
+}
+
+func (r *refreshTokenRepo) RevokeFamily(familyID uuid.UUID) int64 {
+	query := `UPDATE refresh_tokens SET revoked_at = current_timestamp WHERE family_id = :family_id AND revoked_at IS NULL`
+	return execute(r.db, query, Param{"family_id": familyID})
+}
+
+// RevokeDevice ends the device's sessions, expired and revoked tokens of the device are removed
+func (r *refreshTokenRepo) RevokeDevice(tx *sqlx.Tx, deviceID uuid.UUID) int64 {
+	params := Param{"device_id": deviceID}
+	deleteQuery := `
+		DELETE FROM refresh_tokens
+		WHERE device_id = :device_id AND (expires_at < current_timestamp OR revoked_at IS NOT NULL)`
+	executeTransaction(tx, deleteQuery, params)
+	query := `UPDATE refresh_tokens SET revoked_at = current_timestamp WHERE device_id = :device_id AND revoked_at IS NULL`
+	return executeTransaction(tx, query, params)
+}
+
+// IsSessionActive tells whether access tokens of the family are still accepted.
+// Tokens issued before refresh tokens have no family, they are accepted until the device starts a session.
+func (r *refreshTokenRepo) IsSessionActive(deviceID, familyID uuid.UUID) bool {
+	if familyID == uuid.Nil {
+		query := `SELECT NOT EXISTS (SELECT 1 FROM refresh_tokens WHERE device_id = :device_id)`
+		return selectOne[bool](r.db, query, Param{"device_id": deviceID})
+	}
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE device_id = :device_id AND family_id = :family_id AND revoked_at IS NULL AND expires_at > current_timestamp
+		)`
+	return selectOne[bool](r.db, query, Param{"device_id": deviceID, "family_id": familyID})
+}
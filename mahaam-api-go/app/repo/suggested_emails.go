@@ -2,12 +2,14 @@ package repo
 
 import (
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type SuggestedEmailRepo interface {
 	Create(userID uuid.UUID, email string)
 	Delete(id uuid.UUID) int64
-	DeleteManyByEmail(email string) int64
+	DeleteManyByEmail(tx *sqlx.Tx, email string) int64
+	ReplaceEmail(tx *sqlx.Tx, oldEmail, newEmail string) int64
 	GetMany(userID uuid.UUID) []SuggestedEmail
 	GetOne(id uuid.UUID) *SuggestedEmail
 }
@@ -20,11 +22,13 @@ func NewSuggestedEmailRepo(db *AppDB) SuggestedEmailRepo {
 	return &suggestedEmailRepo{db: db}
 }
 
+// Create adds the email to the user's contacts, sharing with it again counts towards its ranking
 func (r *suggestedEmailRepo) Create(userID uuid.UUID, email string) {
 	query := `
-		INSERT INTO suggested_emails (id, user_id, email, created_at)
-		VALUES (:id, :user_id, :email, current_timestamp)
-		ON CONFLICT (user_id, email) DO NOTHING`
+		INSERT INTO suggested_emails (id, user_id, email, share_count, last_shared_at, created_at)
+		VALUES (:id, :user_id, :email, 1, current_timestamp, current_timestamp)
+		ON CONFLICT (user_id, email) DO UPDATE
+		SET share_count = suggested_emails.share_count + 1, last_shared_at = current_timestamp`
 
 	id := uuid.New()
 	params := Param{"id": id, "user_id": userID, "email": email}
@@ -37,28 +41,46 @@ func (r *suggestedEmailRepo) Delete(id uuid.UUID) int64 {
 	return execute(r.db, query, param)
 }
 
-func (r *suggestedEmailRepo) DeleteManyByEmail(email string) int64 {
+func (r *suggestedEmailRepo) DeleteManyByEmail(tx *sqlx.Tx, email string) int64 {
 	query := `DELETE FROM suggested_emails WHERE email = :email`
 	param := Param{"email": email}
-	return execute(r.db, query, param)
+	return executeTransaction(tx, query, param)
 }
 
+// contactRank orders contacts by how often they were shared with, halving the weight every 30 days since the last share
+const contactRank = `s.share_count * power(0.5, EXTRACT(EPOCH FROM current_timestamp - COALESCE(s.last_shared_at, s.created_at)) / 2592000.0) DESC`
+
+// GetMany returns the user's contacts, most shared with recently first, with the names of those who have an account
 func (r *suggestedEmailRepo) GetMany(userID uuid.UUID) []SuggestedEmail {
 	query := `
-		SELECT id, user_id, email, created_at
-		FROM suggested_emails
-		WHERE user_id = :user_id
-		ORDER BY created_at DESC`
+		SELECT s.id, s.user_id, s.email, u.name, s.share_count, s.last_shared_at, s.created_at
+		FROM suggested_emails s
+		LEFT JOIN users u ON u.email = s.email
+		WHERE s.user_id = :user_id
+		ORDER BY ` + contactRank + `, s.created_at DESC`
 	param := Param{"user_id": userID}
 	return selectMany[SuggestedEmail](r.db, query, param)
 }
 
 func (r *suggestedEmailRepo) GetOne(id uuid.UUID) *SuggestedEmail {
 	query := `
-		SELECT id, user_id, email, created_at
-		FROM suggested_emails
-		WHERE id = :id`
+		SELECT s.id, s.user_id, s.email, u.name, s.share_count, s.last_shared_at, s.created_at
+		FROM suggested_emails s
+		LEFT JOIN users u ON u.email = s.email
+		WHERE s.id = :id`
 	param := Param{"id": id}
 	email := selectOne[SuggestedEmail](r.db, query, param)
+	if email.ID == uuid.Nil {
+		return nil
+	}
 	return &email
 }
+
+// ReplaceEmail points suggestions of the old email to the new one, dropping those whose user already has the new one
+func (r *suggestedEmailRepo) ReplaceEmail(tx *sqlx.Tx, oldEmail, newEmail string) int64 {
+	params := Param{"old_email": oldEmail, "new_email": newEmail}
+	executeTransaction(tx, `
+		DELETE FROM suggested_emails s WHERE s.email = :old_email
+		AND EXISTS (SELECT 1 FROM suggested_emails n WHERE n.user_id = s.user_id AND n.email = :new_email)`, params)
+	return executeTransaction(tx, `UPDATE suggested_emails SET email = :new_email WHERE email = :old_email`, params)
+}
@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"mahaam-api/app/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TakeoutRepo interface {
+	Create(userID uuid.UUID) uuid.UUID
+	GetOne(id uuid.UUID) *TakeoutJob
+	GetActive(userID uuid.UUID) *TakeoutJob
+	ClaimNext(staleBefore time.Time) *TakeoutJob
+	Heartbeat(id uuid.UUID) int64
+	Complete(id uuid.UUID, expiresAt time.Time) int64
+	Fail(id uuid.UUID, reason string) int64
+	IsDownloadable(id uuid.UUID) bool
+	DeleteExpired() []uuid.UUID
+	GetProfile(userID uuid.UUID) TakeoutProfile
+	GetOwnedPlans(userID uuid.UUID) []TakeoutPlan
+	GetMemberships(userID uuid.UUID) []TakeoutMembership
+	GetOwnedTasks(userID uuid.UUID) []TakeoutTask
+}
+
+type takeoutRepo struct {
+	db *AppDB
+}
+
+func NewTakeoutRepo(db *AppDB) TakeoutRepo {
+	return &takeoutRepo{db: db}
+}
+
+func (r *takeoutRepo) Create(userID uuid.UUID) uuid.UUID {
+	id := uuid.New()
+	query := `INSERT INTO takeout_jobs (id, user_id, status, created_at) VALUES (:id, :user_id, :status, current_timestamp)`
+	params := Param{"id": id, "user_id": userID, "status": models.TakeoutStatusPending}
+	execute(r.db, query, params)
+	return id
+}
+
+func (r *takeoutRepo) GetOne(id uuid.UUID) *TakeoutJob {
+	query := `SELECT id, user_id, status, error, created_at, completed_at, expires_at FROM takeout_jobs WHERE id = :id`
+	job := selectOne[TakeoutJob](r.db, query, Param{"id": id})
+	if job.ID == uuid.Nil {
+		return nil
+	}
+	return &job
+}
+
+// GetActive returns the user's pending or running job, if any
+func (r *takeoutRepo) GetActive(userID uuid.UUID) *TakeoutJob {
+	query := `
+		SELECT id, user_id, status, error, created_at, completed_at, expires_at FROM takeout_jobs
+		WHERE user_id = :user_id AND status IN (:pending, :running)
+		ORDER BY created_at DESC LIMIT 1`
+	params := Param{"user_id": userID, "pending": models.TakeoutStatusPending, "running": models.TakeoutStatusRunning}
+	job := selectOne[TakeoutJob](r.db, query, params)
+	if job.ID == uuid.Nil {
+		return nil
+	}
+	return &job
+}
+
+// ClaimNext marks the oldest pending job as running and returns it, running jobs whose lease was not renewed
+// since staleBefore are retried. SKIP LOCKED makes each job claimed by one node only.
+func (r *takeoutRepo) ClaimNext(staleBefore time.Time) *TakeoutJob {
+	query := `
+		UPDATE takeout_jobs SET status = :running, started_at = current_timestamp, heartbeat_at = current_timestamp
+		WHERE id = (
+			SELECT id FROM takeout_jobs
+			WHERE status = :pending OR (status = :running AND heartbeat_at < :stale_before)
+			ORDER BY created_at LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, error, created_at, completed_at, expires_at`
+	params := Param{"pending": models.TakeoutStatusPending, "running": models.TakeoutStatusRunning, "stale_before": staleBefore}
+	job := selectOne[TakeoutJob](r.db, query, params)
+	if job.ID == uuid.Nil {
+		return nil
+	}
+	return &job
+}
+
+// Heartbeat renews the lease of a running job
+func (r *takeoutRepo) Heartbeat(id uuid.UUID) int64 {
+	query := `UPDATE takeout_jobs SET heartbeat_at = current_timestamp WHERE id = :id AND status = :running`
+	params := Param{"id": id, "running": models.TakeoutStatusRunning}
+	return execute(r.db, query, params)
+}
+
+func (r *takeoutRepo) Complete(id uuid.UUID, expiresAt time.Time) int64 {
+	query := `
+		UPDATE takeout_jobs SET status = :status, completed_at = current_timestamp, expires_at = :expires_at
+		WHERE id = :id`
+	params := Param{"id": id, "status": models.TakeoutStatusDone, "expires_at": expiresAt}
+	return execute(r.db, query, params)
+}
+
+func (r *takeoutRepo) Fail(id uuid.UUID, reason string) int64 {
+	query := `UPDATE takeout_jobs SET status = :status, error = :error, completed_at = current_timestamp WHERE id = :id`
+	params := Param{"id": id, "status": models.TakeoutStatusFailed, "error": reason}
+	return execute(r.db, query, params)
+}
+
+// IsDownloadable is whether the job is done and its zip not expired
+func (r *takeoutRepo) IsDownloadable(id uuid.UUID) bool {
+	query := `SELECT EXISTS(SELECT 1 FROM takeout_jobs WHERE id = :id AND status = :status AND expires_at > current_timestamp)`
+	params := Param{"id": id, "status": models.TakeoutStatusDone}
+	return selectOne[bool](r.db, query, params)
+}
+
+// DeleteExpired removes jobs with expired zips and failed jobs older than a day, returning their ids
+func (r *takeoutRepo) DeleteExpired() []uuid.UUID {
+	query := `
+		DELETE FROM takeout_jobs
+		WHERE expires_at < current_timestamp
+		OR (status = :failed AND completed_at < current_timestamp - interval '1 day')
+		RETURNING id`
+	return selectMany[uuid.UUID](r.db, query, Param{"failed": models.TakeoutStatusFailed})
+}
+
+func (r *takeoutRepo) GetProfile(userID uuid.UUID) TakeoutProfile {
+	query := `SELECT id, email, name, created_at, updated_at FROM users WHERE id = :user_id`
+	return selectOne[TakeoutProfile](r.db, query, Param{"user_id": userID})
+}
+
+func (r *takeoutRepo) GetOwnedPlans(userID uuid.UUID) []TakeoutPlan {
+	query := `
+		SELECT id, title, type, status, starts, ends, done_percent, created_at, updated_at
+		FROM plans WHERE user_id = :user_id ORDER BY type, sort_order DESC`
+	return selectMany[TakeoutPlan](r.db, query, Param{"user_id": userID})
+}
+
+func (r *takeoutRepo) GetMemberships(userID uuid.UUID) []TakeoutMembership {
+	query := `
+		SELECT cm.plan_id, c.title AS plan_title, u.email AS owner_email, cm.type, cm.created_at
+		FROM plan_members cm
+		JOIN plans c ON c.id = cm.plan_id
+		JOIN users u ON u.id = c.user_id
+		WHERE cm.user_id = :user_id ORDER BY cm.type, cm.sort_order DESC`
+	return selectMany[TakeoutMembership](r.db, query, Param{"user_id": userID})
+}
+
+func (r *takeoutRepo) GetOwnedTasks(userID uuid.UUID) []TakeoutTask {
+	query := `
+		SELECT t.id, t.plan_id, t.title, t.done, t.due, t.created_at, t.updated_at
+		FROM tasks t JOIN plans c ON c.id = t.plan_id
+		WHERE c.user_id = :user_id ORDER BY t.plan_id, t.sort_order DESC`
+	return selectMany[TakeoutTask](r.db, query, Param{"user_id": userID})
+}
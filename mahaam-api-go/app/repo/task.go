@@ -1,20 +1,28 @@
 package repo
 
 import (
+	"fmt"
+	"mahaam-api/app/models"
+
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type TaskRepo interface {
 	GetAll(planID uuid.UUID) []Task
+	GetPage(planID uuid.UUID, q ListQuery) models.Page[Task]
 	GetOne(id uuid.UUID) Task
 	Create(tx *sqlx.Tx, planID uuid.UUID, title string) uuid.UUID
+	CreateImported(tx *sqlx.Tx, planID uuid.UUID, task models.ExportTask) uuid.UUID
 	DeleteOne(tx *sqlx.Tx, id uuid.UUID) int64
 	UpdateDone(tx *sqlx.Tx, id uuid.UUID, done bool) int64
 	UpdateTitle(id uuid.UUID, title string) int64
+	UpdateDue(id uuid.UUID, due *string) int64
 	UpdateOrder(tx *sqlx.Tx, planID uuid.UUID, oldOrder, newOrder int) int64
 	UpdateOrderBeforeDelete(tx *sqlx.Tx, planID uuid.UUID, id uuid.UUID) int64
 	GetCount(planID uuid.UUID) int64
+	Search(userID uuid.UUID, tsQuery string, limit int) []TaskSearchHit
+	GetManyWithPlans(userID uuid.UUID, planID *uuid.UUID, fn func(PlanTaskRow))
 }
 
 type taskRepo struct {
@@ -26,14 +34,35 @@ func NewTaskRepo(db *AppDB) TaskRepo {
 }
 
 func (r *taskRepo) GetAll(planID uuid.UUID) []Task {
-	query := `SELECT id, plan_id, title, done, sort_order, created_at, updated_at
+	query := `SELECT id, plan_id, title, done, due, sort_order, created_at, updated_at
 		FROM tasks WHERE plan_id = :plan_id ORDER BY sort_order DESC`
 	param := Param{"plan_id": planID}
 	return selectMany[Task](r.db, query, param)
 }
 
+var taskOrders = map[models.ListSort]listOrder{
+	models.ListSortManual:  {key: "sort_order", keyType: "int"},
+	models.ListSortCreated: {key: "created_at", keyType: "timestamptz"},
+	models.ListSortUpdated: {key: "coalesce(updated_at, created_at)", keyType: "timestamptz"},
+	models.ListSortDue:     {key: "coalesce(due, DATE 'infinity')", keyType: "date", asc: true},
+}
+
+func (r *taskRepo) GetPage(planID uuid.UUID, q ListQuery) models.Page[Task] {
+	order := taskOrders[q.Sort]
+	query := fmt.Sprintf(`SELECT id, plan_id, title, done, due, sort_order, created_at, updated_at, CAST(%s AS text) AS cursor_key
+		FROM tasks WHERE plan_id = :plan_id`, order.key)
+	params := Param{"plan_id": planID}
+	if q.Done != nil {
+		query += ` AND done = :done`
+		params["done"] = *q.Done
+	}
+	return selectPage(r.db, query, "id", order, q, params, func(t Task) Cursor {
+		return Cursor{Key: t.CursorKey, ID: t.ID}
+	})
+}
+
 func (r *taskRepo) GetOne(id uuid.UUID) Task {
-	query := `SELECT id, plan_id, title, done, sort_order, created_at, updated_at FROM tasks WHERE id = :id`
+	query := `SELECT id, plan_id, title, done, due, sort_order, created_at, updated_at FROM tasks WHERE id = :id`
 	param := Param{"id": id}
 	return selectOne[Task](r.db, query, param)
 }
@@ -47,6 +76,16 @@ func (r *taskRepo) Create(tx *sqlx.Tx, planID uuid.UUID, title string) uuid.UUID
 	return id
 }
 
+// CreateImported adds the task on top of the plan keeping its done and due values
+func (r *taskRepo) CreateImported(tx *sqlx.Tx, planID uuid.UUID, task models.ExportTask) uuid.UUID {
+	id := uuid.New()
+	query := `INSERT INTO tasks (id, plan_id, title, done, due, sort_order, created_at)
+		VALUES (:id, :plan_id, :title, :done, :due, (SELECT COUNT(1) FROM tasks WHERE plan_id = :plan_id), current_timestamp)`
+	params := Param{"id": id, "plan_id": planID, "title": task.Title, "done": task.Done, "due": task.Due}
+	executeTransaction(tx, query, params)
+	return id
+}
+
 func (r *taskRepo) DeleteOne(tx *sqlx.Tx, id uuid.UUID) int64 {
 	query := `DELETE FROM tasks WHERE id = :id`
 	param := Param{"id": id}
@@ -65,6 +104,12 @@ func (r *taskRepo) UpdateTitle(id uuid.UUID, title string) int64 {
 	return execute(r.db, query, params)
 }
 
+func (r *taskRepo) UpdateDue(id uuid.UUID, due *string) int64 {
+	query := `UPDATE tasks SET due = :due, updated_at = current_timestamp WHERE id = :id`
+	params := Param{"id": id, "due": due}
+	return execute(r.db, query, params)
+}
+
 func (r *taskRepo) UpdateOrderBeforeDelete(tx *sqlx.Tx, planID, id uuid.UUID) int64 {
 	query := `UPDATE tasks SET sort_order = sort_order - 1
 		WHERE plan_id = :plan_id 
@@ -96,3 +141,37 @@ func (r *taskRepo) GetCount(planID uuid.UUID) int64 {
 	param := Param{"plan_id": planID}
 	return selectOne[int64](r.db, query, param)
 }
+
+// Search matches task titles within owned and shared plans against a tsquery, best ranked first
+func (r *taskRepo) Search(userID uuid.UUID, tsQuery string, limit int) []TaskSearchHit {
+	query := `
+		SELECT t.id AS task_id, t.plan_id, c.title AS plan_title, c.type AS plan_type,
+			c.user_id <> :user_id AS is_shared, t.done,
+			ts_headline('simple', t.title, q.query, :headline_options) AS snippet,
+			ts_rank(t.search_vector, q.query) AS rank
+		FROM tasks t
+		JOIN plans c ON t.plan_id = c.id, to_tsquery('simple', :query) q(query)
+		WHERE t.search_vector @@ q.query
+		AND (c.user_id = :user_id OR c.id IN (SELECT plan_id FROM plan_members WHERE user_id = :user_id))
+		ORDER BY rank DESC
+		LIMIT :limit`
+	params := Param{"user_id": userID, "query": tsQuery, "headline_options": SearchHeadlineOptions, "limit": limit}
+	return selectMany[TaskSearchHit](r.db, query, params)
+}
+
+// GetManyWithPlans streams owned and shared plans joined with their tasks, plan by plan in the user's order.
+// Plans without tasks come as one row with no task. planID limits it to a single plan.
+func (r *taskRepo) GetManyWithPlans(userID uuid.UUID, planID *uuid.UUID, fn func(PlanTaskRow)) {
+	query := `
+		SELECT c.id AS plan_id, c.title AS plan_title, coalesce(cm.type, c.type) AS plan_type, c.status AS plan_status,
+			c.starts, c.ends, cm.user_id IS NOT NULL AS is_shared, c.created_at,
+			t.id AS task_id, t.title AS task_title, t.done AS task_done, t.due AS task_due
+		FROM plans c
+		LEFT JOIN plan_members cm ON cm.plan_id = c.id AND cm.user_id = :user_id
+		LEFT JOIN tasks t ON t.plan_id = c.id
+		WHERE (c.user_id = :user_id OR cm.user_id IS NOT NULL)
+		AND (CAST(:plan_id AS uuid) IS NULL OR c.id = :plan_id)
+		ORDER BY coalesce(cm.type, c.type), coalesce(cm.sort_order, c.sort_order) DESC, c.id, t.sort_order DESC`
+	params := Param{"user_id": userID, "plan_id": planID}
+	selectEach(r.db, query, params, fn)
+}
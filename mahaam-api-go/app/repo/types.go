@@ -9,5 +9,35 @@ type SuggestedEmail = models.SuggestedEmail
 type Device = models.Device
 type Plan = models.Plan
 type PlanIn = models.PlanIn
+type PlanCategory = models.PlanCategory
 type Task = models.Task
 type User = models.User
+type ListQuery = models.ListQuery
+type Cursor = models.Cursor
+type PlanTaskRow = models.PlanTaskRow
+type PlanSearchHit = models.PlanSearchHit
+type TaskSearchHit = models.TaskSearchHit
+type CalendarFeed = models.CalendarFeed
+type Otp = models.Otp
+type AuthAttempt = models.AuthAttempt
+type RateLimitHit = models.RateLimitHit
+type RefreshToken = models.RefreshToken
+type AccessToken = models.AccessToken
+type OidcLogin = models.OidcLogin
+type Preferences = models.Preferences
+type TakeoutJob = models.TakeoutJob
+type TakeoutProfile = models.TakeoutProfile
+type TakeoutPlan = models.TakeoutPlan
+type TakeoutMembership = models.TakeoutMembership
+type TakeoutTask = models.TakeoutTask
+type ContactGroup = models.ContactGroup
+type ContactGroupMember = models.ContactGroupMember
+type BlockedUser = models.BlockedUser
+
+// Matched terms of search snippets are wrapped in private use characters, not markup, so the service
+// can escape the user's text before turning them into <mark> tags
+const (
+	SearchMatchStart      = "\ue000"
+	SearchMatchStop       = "\ue001"
+	SearchHeadlineOptions = "StartSel=" + SearchMatchStart + ", StopSel=" + SearchMatchStop + ", HighlightAll=true"
+)
@@ -1,17 +1,27 @@
 package repo
 
 import (
+	"mahaam-api/app/models"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type UserRepo interface {
 	Create(tx *sqlx.Tx) uuid.UUID
 	UpdateName(id uuid.UUID, name string) int64
 	UpdateEmail(tx *sqlx.Tx, id uuid.UUID, email string) int64
+	ChangeEmail(tx *sqlx.Tx, id uuid.UUID, email string) bool
 	GetOneByEmail(email string) *User
+	GetManyByEmails(emails []string) []User
 	GetOne(id uuid.UUID) *User
 	Delete(tx *sqlx.Tx, id uuid.UUID) int64
+	ScheduleDeletion(id uuid.UUID, deletesAt time.Time) int64
+	CancelDeletion(tx *sqlx.Tx, id uuid.UUID) int64
+	GetDueForDeletion(limit int) []User
+	DeleteDue(tx *sqlx.Tx, id uuid.UUID) int64
 }
 
 type userRepo struct {
@@ -45,8 +55,21 @@ func (r *userRepo) UpdateEmail(tx *sqlx.Tx, id uuid.UUID, email string) int64 {
 	return executeTransaction(tx, query, params)
 }
 
+// ChangeEmail returns false when another user has the email, as enforced by users_unique_index_email
+func (r *userRepo) ChangeEmail(tx *sqlx.Tx, id uuid.UUID, email string) bool {
+	query := `UPDATE users SET email = :email, updated_at = current_timestamp WHERE id = :id`
+	_, err := tx.NamedExec(query, Param{"id": id, "email": email})
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "users_unique_index_email" {
+		return false
+	}
+	if err != nil {
+		panic(models.ServerError(err.Error()))
+	}
+	return true
+}
+
 func (r *userRepo) GetOneByEmail(email string) *User {
-	query := `SELECT id, name, email FROM users WHERE email = :email`
+	query := `SELECT id, name, email, deletes_at FROM users WHERE email = :email`
 	params := Param{"email": email}
 	user := selectOne[User](r.db, query, params)
 	if user.ID == uuid.Nil {
@@ -55,8 +78,14 @@ func (r *userRepo) GetOneByEmail(email string) *User {
 	return &user
 }
 
+func (r *userRepo) GetManyByEmails(emails []string) []User {
+	query := `SELECT id, name, email, deletes_at FROM users WHERE email = ANY(:emails)`
+	params := Param{"emails": pq.Array(emails)}
+	return selectMany[User](r.db, query, params)
+}
+
 func (r *userRepo) GetOne(id uuid.UUID) *User {
-	query := `SELECT id, name, email FROM users WHERE id = :id`
+	query := `SELECT id, name, email, deletes_at FROM users WHERE id = :id`
 	params := Param{"id": id}
 	user := selectOne[User](r.db, query, params)
 	if user.ID == uuid.Nil {
@@ -70,3 +99,31 @@ func (r *userRepo) Delete(tx *sqlx.Tx, id uuid.UUID) int64 {
 	params := Param{"id": id}
 	return executeTransaction(tx, query, params)
 }
+
+func (r *userRepo) ScheduleDeletion(id uuid.UUID, deletesAt time.Time) int64 {
+	query := `UPDATE users SET deletes_at = :deletes_at, updated_at = current_timestamp WHERE id = :id`
+	params := Param{"id": id, "deletes_at": deletesAt}
+	return execute(r.db, query, params)
+}
+
+func (r *userRepo) CancelDeletion(tx *sqlx.Tx, id uuid.UUID) int64 {
+	query := `UPDATE users SET deletes_at = NULL, updated_at = current_timestamp WHERE id = :id AND deletes_at IS NOT NULL`
+	params := Param{"id": id}
+	return executeTransaction(tx, query, params)
+}
+
+func (r *userRepo) GetDueForDeletion(limit int) []User {
+	query := `
+		SELECT id, name, email, deletes_at FROM users
+		WHERE deletes_at <= current_timestamp
+		ORDER BY deletes_at LIMIT :limit`
+	params := Param{"limit": limit}
+	return selectMany[User](r.db, query, params)
+}
+
+// DeleteDue deletes the user only if its deletion is still due, so a deletion cancelled meanwhile is kept
+func (r *userRepo) DeleteDue(tx *sqlx.Tx, id uuid.UUID) int64 {
+	query := `DELETE FROM users WHERE id = :id AND deletes_at <= current_timestamp`
+	params := Param{"id": id}
+	return executeTransaction(tx, query, params)
+}
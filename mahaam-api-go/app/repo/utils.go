@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"mahaam-api/app/models"
+	"strconv"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -59,6 +61,32 @@ func selectMany[T any](db *AppDB, query string, arg any) []T {
 	return items
 }
 
+// selectEach streams the rows of query to fn one at a time instead of loading them all in memory
+func selectEach[T any](db *AppDB, query string, arg any, fn func(T)) {
+	stmt, err := db.PrepareNamed(query)
+	if err != nil {
+		panic(models.ServerError(err.Error()))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Queryx(arg)
+	if err != nil {
+		panic(models.ServerError(err.Error()))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		if err := rows.StructScan(&item); err != nil {
+			panic(models.ServerError(err.Error()))
+		}
+		fn(item)
+	}
+	if err := rows.Err(); err != nil {
+		panic(models.ServerError(err.Error()))
+	}
+}
+
 func execute(db *AppDB, query string, arg any) int64 {
 	result, err := db.NamedExec(query, arg)
 	if err != nil {
@@ -84,7 +112,8 @@ func executeTransaction(tx *sqlx.Tx, query string, arg any) int64 {
 	return rows
 }
 
-func WithTransaction(db *AppDB, fn func(tx *sqlx.Tx) error) error {
+// WithTransaction commits when fn returns nil, and rolls back when it returns an error or panics
+func WithTransaction(db *AppDB, fn func(tx *sqlx.Tx) error) (err error) {
 	tx, err := db.Beginx()
 	if err != nil {
 		return err
@@ -101,3 +130,78 @@ func WithTransaction(db *AppDB, fn func(tx *sqlx.Tx) error) error {
 	}()
 	return fn(tx)
 }
+
+// listOrder is how a paged list is sorted: key is the sql expression ordered by and used as the cursor key,
+// keyType is the sql type the cursor key is cast back to. pinned is an optional boolean expression
+// sorted before the key, true first, kept in the cursor too.
+type listOrder struct {
+	key     string
+	keyType string
+	asc     bool
+	pinned  string
+}
+
+// cursorTimeLayouts are the text forms of timestamptz keys, with the offset in hours or hours and minutes
+var cursorTimeLayouts = []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00"}
+
+// isKey tells whether a cursor key can be cast back to keyType, so crafted cursors fail as input errors
+func (o listOrder) isKey(key string) bool {
+	switch o.keyType {
+	case "int":
+		_, err := strconv.Atoi(key)
+		return err == nil
+	case "date":
+		_, err := time.Parse(time.DateOnly, key)
+		return err == nil || key == "infinity"
+	case "timestamptz":
+		for _, layout := range cursorTimeLayouts {
+			if _, err := time.Parse(layout, key); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectPage appends the cursor condition, ordering and limit to query, which must end with a WHERE clause,
+// and fetches one extra row to know whether a next page exists.
+// query is expected to select the order key as text named cursor_key.
+func selectPage[T any](db *AppDB, query, idColumn string, order listOrder, q ListQuery, params Param, cursorOf func(T) Cursor) models.Page[T] {
+	direction, comparison := "DESC", "<"
+	if order.asc {
+		direction, comparison = "ASC", ">"
+	}
+	if q.Cursor != nil {
+		if !order.isKey(q.Cursor.Key) {
+			panic(models.InputError("cursor is not valid"))
+		}
+		after := fmt.Sprintf("(%s, %s) %s (CAST(:cursor_key AS %s), :cursor_id)", order.key, idColumn, comparison, order.keyType)
+		if order.pinned != "" {
+			after = fmt.Sprintf("(%s) < :cursor_pinned OR ((%s) = :cursor_pinned AND %s)", order.pinned, order.pinned, after)
+			params["cursor_pinned"] = q.Cursor.Pinned
+		}
+		query += " AND (" + after + ")"
+		params["cursor_key"] = q.Cursor.Key
+		params["cursor_id"] = q.Cursor.ID
+	}
+	query += " ORDER BY "
+	if order.pinned != "" {
+		query += fmt.Sprintf("(%s) DESC, ", order.pinned)
+	}
+	query += fmt.Sprintf("%s %s, %s %s LIMIT :limit", order.key, direction, idColumn, direction)
+	params["limit"] = q.Limit + 1
+
+	items := selectMany[T](db, query, params)
+	page := models.Page[T]{Items: items}
+	if len(items) > q.Limit {
+		page.Items = items[:q.Limit]
+		cursor := cursorOf(page.Items[q.Limit-1])
+		cursor.Sort = q.Sort
+		next := cursor.Encode()
+		page.NextCursor = &next
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
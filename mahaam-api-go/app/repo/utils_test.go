@@ -0,0 +1,31 @@
+package repo
+
+import "testing"
+
+func TestListOrderIsKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyType string
+		key     string
+		want    bool
+	}{
+		{"int", "int", "3", true},
+		{"int of timestamp", "int", "2024-05-01 10:00:00+00", false},
+		{"date", "date", "2024-05-01", true},
+		{"infinity date", "date", "infinity", true},
+		{"date of int", "date", "3", false},
+		{"timestamptz", "timestamptz", "2024-05-01 10:00:00.123456+00", true},
+		{"timestamptz without fraction", "timestamptz", "2024-05-01 10:00:00+03", true},
+		{"timestamptz with minutes offset", "timestamptz", "2024-05-01 10:00:00+05:30", true},
+		{"timestamptz of manual order", "timestamptz", "3", false},
+		{"empty", "timestamptz", "", false},
+		{"sql", "int", "1) OR (1=1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (listOrder{keyType: tt.keyType}).isKey(tt.key); got != tt.want {
+				t.Errorf("isKey(%q) of %s = %v, want %v", tt.key, tt.keyType, got, tt.want)
+			}
+		})
+	}
+}
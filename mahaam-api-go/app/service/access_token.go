@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	token "mahaam-api/utils/token"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccessTokenService interface {
+	Create(userID uuid.UUID, name string, scopes []string, expiresInDays int) *AccessToken
+	GetMany(userID uuid.UUID) []AccessToken
+	Delete(userID, id uuid.UUID)
+}
+
+type accessTokenService struct {
+	accessTokenRepo repo.AccessTokenRepo
+}
+
+func NewAccessTokenService(accessTokenRepo repo.AccessTokenRepo) AccessTokenService {
+	return &accessTokenService{accessTokenRepo: accessTokenRepo}
+}
+
+const (
+	accessTokensLimit           = 20
+	accessTokenNameMaxSize      = 100
+	accessTokenMaxValidDays     = 365
+	accessTokenDefaultValidDays = 90
+)
+
+var accessTokenScopes = []string{models.AccessTokenScopeRead, models.AccessTokenScopeTasks, models.AccessTokenScopePlans}
+
+// Create creates a personal access token, the token is returned only here. Without expiresInDays it expires in 90 days.
+func (s *accessTokenService) Create(userID uuid.UUID, name string, scopes []string, expiresInDays int) *AccessToken {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > accessTokenNameMaxSize {
+		panic(models.InputError(fmt.Sprintf("name should be between 1 and %d characters", accessTokenNameMaxSize)))
+	}
+	if len(scopes) == 0 {
+		panic(models.InputError("scopes is required"))
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(accessTokenScopes, scope) {
+			panic(models.InputError("scopes should be of " + strings.Join(accessTokenScopes, ", ")))
+		}
+	}
+	if expiresInDays < 0 || expiresInDays > accessTokenMaxValidDays {
+		panic(models.InputError(fmt.Sprintf("expiresInDays should be between 1 and %d", accessTokenMaxValidDays)))
+	}
+	if expiresInDays == 0 {
+		expiresInDays = accessTokenDefaultValidDays
+	}
+	if s.accessTokenRepo.GetCount(userID) >= accessTokensLimit {
+		panic(models.LogicError("maximum of 20 access tokens reached", "max_is_20"))
+	}
+
+	slices.Sort(scopes)
+	accessToken := &AccessToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    slices.Compact(scopes),
+		Token:     models.AccessTokenPrefix + token.NewOpaqueToken(),
+		CreatedAt: time.Now(),
+	}
+	expiresAt := accessToken.CreatedAt.AddDate(0, 0, expiresInDays)
+	accessToken.ExpiresAt = &expiresAt
+	s.accessTokenRepo.Create(accessToken, token.HashOpaqueToken(accessToken.Token))
+	return accessToken
+}
+
+func (s *accessTokenService) GetMany(userID uuid.UUID) []AccessToken {
+	return s.accessTokenRepo.GetMany(userID)
+}
+
+func (s *accessTokenService) Delete(userID, id uuid.UUID) {
+	if s.accessTokenRepo.Delete(userID, id) == 0 {
+		panic(models.NotFoundError("access token not found"))
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// attemptGuard counts attempts of keys and locks them after the free ones, each attempt beyond doubles the lock.
+// An attempt is counted before it is made and the keys are reset when it succeeds, so concurrent attempts
+// cannot get past the lock.
+type attemptGuard struct {
+	authAttemptRepo repo.AuthAttemptRepo
+	freeAttempts    int
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	errKey          string
+}
+
+func newOtpVerifyGuard(authAttemptRepo repo.AuthAttemptRepo) attemptGuard {
+	return attemptGuard{authAttemptRepo: authAttemptRepo, freeAttempts: 5, baseDelay: time.Minute, maxDelay: 24 * time.Hour, errKey: "otp_verify_locked"}
+}
+
+func otpVerifyKey(email string) string {
+	return "otp-verify:" + strings.ToLower(email)
+}
+
+func otpVerifyDeviceKey(deviceID uuid.UUID) string {
+	return "otp-verify-device:" + deviceID.String()
+}
+
+// take counts an attempt of each key, and panics with a too many attempts error when any of them is locked.
+// It returns how long the keys are locked by this attempt, for when it fails.
+func (g attemptGuard) take(keys ...string) time.Duration {
+	var locked, wait time.Duration
+	for _, key := range keys {
+		attempt := g.authAttemptRepo.Take(key, g.freeAttempts, g.delays())
+		if attempt == nil {
+			locked = max(locked, g.lockedFor(g.authAttemptRepo.GetMany([]string{key})), time.Second)
+			continue
+		}
+		if attempt.LockedUntil != nil {
+			wait = max(wait, time.Until(*attempt.LockedUntil))
+		}
+	}
+	if locked > 0 {
+		panic(models.TooManyAttemptsError(g.errKey, locked))
+	}
+	return wait
+}
+
+func (g attemptGuard) reset(keys ...string) {
+	g.authAttemptRepo.Delete(keys)
+}
+
+// delays are the locks after each attempt beyond the free ones, doubling from baseDelay up to maxDelay
+func (g attemptGuard) delays() []int {
+	delays := []int{}
+	for delay := g.baseDelay; ; delay *= 2 {
+		if delay >= g.maxDelay {
+			return append(delays, int(g.maxDelay.Seconds()))
+		}
+		delays = append(delays, int(delay.Seconds()))
+	}
+}
+
+func (g attemptGuard) lockedFor(attempts []AuthAttempt) time.Duration {
+	var wait time.Duration
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil {
+			wait = max(wait, time.Until(*attempt.LockedUntil))
+		}
+	}
+	return wait
+}
+
+// rateLimit allows a number of hits per key in a fixed window, hits are counted even when rejected
+type rateLimit struct {
+	rateLimitRepo repo.RateLimitRepo
+	limit         int
+	window        time.Duration
+	errKey        string
+}
+
+func newInviteRateLimit(rateLimitRepo repo.RateLimitRepo) rateLimit {
+	return rateLimit{rateLimitRepo: rateLimitRepo, limit: 20, window: 24 * time.Hour, errKey: "invites_limit_reached"}
+}
+
+func newOtpSendRateLimit(rateLimitRepo repo.RateLimitRepo) rateLimit {
+	return rateLimit{rateLimitRepo: rateLimitRepo, limit: 5, window: time.Hour, errKey: "otp_send_throttled"}
+}
+
+func inviteKey(userID string) string {
+	return "invite:" + userID
+}
+
+func otpSendKey(email string) string {
+	return "otp-send:" + strings.ToLower(email)
+}
+
+func otpSendDeviceKey(deviceID uuid.UUID) string {
+	return "otp-send-device:" + deviceID.String()
+}
+
+// take counts a hit of each key, and panics with a too many attempts error when any of them is over the limit
+func (l rateLimit) take(keys ...string) {
+	var wait time.Duration
+	for _, key := range keys {
+		wait = max(wait, l.waitFor(l.rateLimitRepo.Hit(key, int(l.window.Seconds()))))
+	}
+	if wait > 0 {
+		panic(models.TooManyAttemptsError(l.errKey, wait))
+	}
+}
+
+// waitFor is how long until the key's window ends when it is over the limit, zero otherwise
+func (l rateLimit) waitFor(hit RateLimitHit) time.Duration {
+	if hit.Hits <= l.limit {
+		return 0
+	}
+	return max(time.Until(hit.WindowStartedAt.Add(l.window)), time.Second)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"mahaam-api/app/models"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeAuthAttemptRepo counts attempts in memory the way the auth_attempts statement does
+type fakeAuthAttemptRepo struct {
+	attempts map[string]*AuthAttempt
+}
+
+func (r *fakeAuthAttemptRepo) GetMany(keys []string) []AuthAttempt {
+	result := []AuthAttempt{}
+	for _, key := range keys {
+		if attempt, ok := r.attempts[key]; ok {
+			result = append(result, *attempt)
+		}
+	}
+	return result
+}
+
+func (r *fakeAuthAttemptRepo) Take(key string, freeAttempts int, delays []int) *AuthAttempt {
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &AuthAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+		return nil
+	}
+	attempt.Attempts++
+	attempt.LastAttemptAt = time.Now()
+	attempt.LockedUntil = nil
+	if attempt.Attempts >= freeAttempts {
+		delay := delays[min(attempt.Attempts-freeAttempts, len(delays)-1)]
+		lockedUntil := time.Now().Add(time.Duration(delay) * time.Second)
+		attempt.LockedUntil = &lockedUntil
+	}
+	taken := *attempt
+	return &taken
+}
+
+func (r *fakeAuthAttemptRepo) Delete(keys []string) int64 {
+	for _, key := range keys {
+		delete(r.attempts, key)
+	}
+	return int64(len(keys))
+}
+
+// unlock ends the key's lock, as if its delay passed
+func (r *fakeAuthAttemptRepo) unlock(key string) {
+	r.attempts[key].LockedUntil = nil
+}
+
+type fakeRateLimitRepo struct {
+	hits map[string]*RateLimitHit
+}
+
+func (r *fakeRateLimitRepo) Hit(key string, windowSeconds int) RateLimitHit {
+	hit, ok := r.hits[key]
+	if !ok || hit.WindowStartedAt.Before(time.Now().Add(-time.Duration(windowSeconds)*time.Second)) {
+		hit = &RateLimitHit{Key: key, WindowStartedAt: time.Now()}
+		r.hits[key] = hit
+	}
+	hit.Hits++
+	return *hit
+}
+
+// tooManyAttempts runs fn and returns the too many attempts error it panics with, nil when it does not
+func tooManyAttempts(t *testing.T, fn func()) (err *models.Err) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*models.Err)
+			if !ok || e.Code != 429 {
+				t.Fatalf("unexpected panic: %v", r)
+			}
+			err = e
+		}
+	}()
+	fn()
+	return nil
+}
+
+func TestAttemptGuardDelays(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseDelay time.Duration
+		maxDelay  time.Duration
+		want      []int
+	}{
+		{"doubles up to max", time.Minute, 10 * time.Minute, []int{60, 120, 240, 480, 600}},
+		{"max is a power of two of base", time.Minute, 4 * time.Minute, []int{60, 120, 240}},
+		{"base at max", time.Hour, time.Hour, []int{3600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := attemptGuard{baseDelay: tt.baseDelay, maxDelay: tt.maxDelay}
+			if got := g.delays(); !slices.Equal(got, tt.want) {
+				t.Errorf("delays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptGuardTake(t *testing.T) {
+	tests := []struct {
+		name string
+		// steps are the attempts made in order, "take" counts one, "unlock" lets the lock pass, "reset" resets the keys
+		steps       []string
+		wantLocked  bool
+		wantWaitMin time.Duration
+	}{
+		{name: "free attempts", steps: []string{"take", "take"}},
+		{name: "last free attempt locks the next", steps: []string{"take", "take", "take"}, wantWaitMin: 50 * time.Second},
+		{name: "attempt while locked", steps: []string{"take", "take", "take", "take"}, wantLocked: true},
+		{name: "lock doubles after it passes", steps: []string{"take", "take", "take", "unlock", "take"}, wantWaitMin: 110 * time.Second},
+		{name: "reset on success", steps: []string{"take", "take", "take", "reset", "take"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeAuthAttemptRepo{attempts: map[string]*AuthAttempt{}}
+			g := attemptGuard{authAttemptRepo: r, freeAttempts: 3, baseDelay: time.Minute, maxDelay: time.Hour, errKey: "locked"}
+			keys := []string{otpVerifyKey("A@mahaam.dev"), "device"}
+			var wait time.Duration
+			var err *models.Err
+			for _, step := range tt.steps {
+				switch step {
+				case "take":
+					err = tooManyAttempts(t, func() { wait = g.take(keys...) })
+				case "unlock":
+					r.unlock(keys[0])
+					r.unlock(keys[1])
+				case "reset":
+					g.reset(keys...)
+				}
+			}
+			if (err != nil) != tt.wantLocked {
+				t.Fatalf("locked = %v, want %v", err != nil, tt.wantLocked)
+			}
+			if err != nil && (err.Key != "locked" || err.RetryAfter <= 0) {
+				t.Errorf("error = %+v, want key locked with a retry after", err)
+			}
+			if !tt.wantLocked && (wait < tt.wantWaitMin || (tt.wantWaitMin == 0 && wait != 0)) {
+				t.Errorf("wait = %v, want at least %v", wait, tt.wantWaitMin)
+			}
+		})
+	}
+}
+
+func TestAttemptGuardKeysAreCaseInsensitive(t *testing.T) {
+	if otpVerifyKey("A@Mahaam.dev") != otpVerifyKey("a@mahaam.dev") {
+		t.Error("otpVerifyKey differs by email case")
+	}
+	if otpSendKey("A@Mahaam.dev") != otpSendKey("a@mahaam.dev") {
+		t.Error("otpSendKey differs by email case")
+	}
+}
+
+func TestRateLimitTake(t *testing.T) {
+	tests := []struct {
+		name       string
+		hits       int
+		wantLocked bool
+	}{
+		{"under the limit", 2, false},
+		{"at the limit", 3, false},
+		{"over the limit", 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := rateLimit{rateLimitRepo: &fakeRateLimitRepo{hits: map[string]*RateLimitHit{}}, limit: 3, window: time.Hour, errKey: "limited"}
+			var err *models.Err
+			for range tt.hits {
+				err = tooManyAttempts(t, func() { l.take("email", "device") })
+			}
+			if (err != nil) != tt.wantLocked {
+				t.Fatalf("limited = %v, want %v", err != nil, tt.wantLocked)
+			}
+			if err != nil && (err.Key != "limited" || err.RetryAfter < 3590) {
+				t.Errorf("error = %+v, want key limited retrying after the window", err)
+			}
+		})
+	}
+}
+
+func TestRateLimitWaitFor(t *testing.T) {
+	l := rateLimit{limit: 5, window: time.Hour}
+	tests := []struct {
+		name string
+		hit  RateLimitHit
+		want time.Duration
+	}{
+		{"within the limit", RateLimitHit{Hits: 5, WindowStartedAt: time.Now()}, 0},
+		{"over the limit", RateLimitHit{Hits: 6, WindowStartedAt: time.Now().Add(-30 * time.Minute)}, 30 * time.Minute},
+		{"over the limit of an ended window", RateLimitHit{Hits: 6, WindowStartedAt: time.Now().Add(-2 * time.Hour)}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := l.waitFor(tt.hit)
+			if got < tt.want-time.Second || got > tt.want {
+				t.Errorf("waitFor() = %v, want about %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	token "mahaam-api/utils/token"
+
+	"github.com/google/uuid"
+)
+
+type CalendarFeedService interface {
+	Get(userID uuid.UUID) *CalendarFeed
+	Rotate(userID uuid.UUID) *CalendarFeed
+	Revoke(userID uuid.UUID)
+	Write(feedToken string, w io.Writer)
+}
+
+type calendarFeedService struct {
+	calendarFeedRepo repo.CalendarFeedRepo
+	exportService    ExportService
+}
+
+func NewCalendarFeedService(calendarFeedRepo repo.CalendarFeedRepo, exportService ExportService) CalendarFeedService {
+	return &calendarFeedService{calendarFeedRepo: calendarFeedRepo, exportService: exportService}
+}
+
+// calendarFeedPath is where calendar apps fetch the feed, it is outside jwt auth and protected by the token only
+const calendarFeedPath = "/calendar/"
+
+func (s *calendarFeedService) Get(userID uuid.UUID) *CalendarFeed {
+	feed := s.calendarFeedRepo.GetOne(userID)
+	if feed == nil {
+		panic(models.NotFoundError("calendar feed not found"))
+	}
+	return feed
+}
+
+// Rotate creates the feed, or replaces its token when it exists so the old url stops working
+func (s *calendarFeedService) Rotate(userID uuid.UUID) *CalendarFeed {
+	feedToken := token.NewOpaqueToken()
+	s.calendarFeedRepo.Upsert(userID, token.HashOpaqueToken(feedToken))
+	feed := s.calendarFeedRepo.GetOne(userID)
+	feed.Token = feedToken
+	feed.Path = "/mahaam-api" + calendarFeedPath + feedToken + ".ics"
+	return feed
+}
+
+func (s *calendarFeedService) Revoke(userID uuid.UUID) {
+	if s.calendarFeedRepo.Delete(userID) == 0 {
+		panic(models.NotFoundError("calendar feed not found"))
+	}
+}
+
+// Write writes the ics calendar of the feed owner's plans and tasks, owned and shared
+func (s *calendarFeedService) Write(feedToken string, w io.Writer) {
+	userID := s.calendarFeedRepo.GetUserID(token.HashOpaqueToken(feedToken))
+	if userID == uuid.Nil {
+		panic(models.NotFoundError("calendar feed not found"))
+	}
+	s.calendarFeedRepo.UpdateLastAccessed(userID)
+	s.exportService.Export(userID, nil, models.ExportFormatICS, w)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
+	logs "mahaam-api/utils/log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CleanupService interface {
+	CleanupAnonymousUsers() *models.CleanupRun
+	StartAnonymousCleanup(ctx context.Context)
+}
+
+type cleanupService struct {
+	cleanupRepo repo.CleanupRepo
+	cfg         *conf.Conf
+	logger      logs.Logger
+}
+
+func NewCleanupService(cleanupRepo repo.CleanupRepo, cfg *conf.Conf, logger logs.Logger) CleanupService {
+	return &cleanupService{cleanupRepo: cleanupRepo, cfg: cfg, logger: logger}
+}
+
+const (
+	defaultAnonymousInactiveDays = 180
+	anonymousCleanupBatchSize    = 500
+	// anonymousCleanupLockKey is the advisory lock letting one node at a time run the cleanup
+	anonymousCleanupLockKey int64 = 72_001
+)
+
+// CleanupAnonymousUsers deletes abandoned anonymous users in batches, or only counts them in dry-run mode.
+// It returns nil when another node is running the cleanup.
+func (s *cleanupService) CleanupAnonymousUsers() *models.CleanupRun {
+	unlock, ok := s.cleanupRepo.TryLock(anonymousCleanupLockKey)
+	if !ok {
+		return nil
+	}
+	defer unlock()
+
+	inactiveDays := s.cfg.AnonymousInactiveDays
+	if inactiveDays <= 0 {
+		inactiveDays = defaultAnonymousInactiveDays
+	}
+	inactiveSince := time.Now().AddDate(0, 0, -inactiveDays)
+	run := &models.CleanupRun{
+		ID:           uuid.New(),
+		HealthID:     conf.Env().HealthID,
+		DryRun:       s.cfg.AnonymousCleanupDryRun,
+		InactiveDays: inactiveDays,
+		StartedAt:    time.Now(),
+	}
+
+	run.Candidates = s.cleanupRepo.CountAbandonedUsers(inactiveSince)
+	if !run.DryRun {
+		for {
+			deleted := s.cleanupRepo.DeleteAbandonedUsers(inactiveSince, anonymousCleanupBatchSize)
+			run.Deleted += deleted
+			if deleted < anonymousCleanupBatchSize {
+				break
+			}
+		}
+	}
+	run.FinishedAt = time.Now()
+	s.cleanupRepo.CreateRun(run)
+	return run
+}
+
+func (s *cleanupService) StartAnonymousCleanup(ctx context.Context) {
+	if !s.cfg.AnonymousCleanupEnabled {
+		return
+	}
+	go s.startAnonymousCleanup(ctx)
+}
+
+func (s *cleanupService) startAnonymousCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runAnonymousCleanup()
+		}
+	}
+}
+
+func (s *cleanupService) runAnonymousCleanup() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Anonymous users cleanup failed: %v", r)
+		}
+	}()
+	run := s.CleanupAnonymousUsers()
+	if run == nil || run.Candidates == 0 {
+		return
+	}
+	if run.DryRun {
+		s.logger.Info(uuid.Nil, "Anonymous users cleanup dry run: %d users inactive for %d days would be deleted", run.Candidates, run.InactiveDays)
+		return
+	}
+	s.logger.Info(uuid.Nil, "Anonymous users cleanup deleted %d of %d users inactive for %d days in %s",
+		run.Deleted, run.Candidates, run.InactiveDays, run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type ContactService interface {
+	GetMany(userID uuid.UUID) []SuggestedEmail
+	Delete(userID uuid.UUID, contactID uuid.UUID)
+	GetGroups(userID uuid.UUID) []ContactGroup
+	CreateGroup(userID uuid.UUID, name string) uuid.UUID
+	RenameGroup(userID uuid.UUID, groupID uuid.UUID, name string)
+	DeleteGroup(userID uuid.UUID, groupID uuid.UUID)
+	AddToGroup(userID uuid.UUID, groupID, contactID uuid.UUID)
+	RemoveFromGroup(userID uuid.UUID, groupID, contactID uuid.UUID)
+	GetBlocked(userID uuid.UUID) []BlockedUser
+	Block(userID uuid.UUID, email string)
+	Unblock(userID uuid.UUID, blockedUserID uuid.UUID)
+}
+
+type contactService struct {
+	suggestedEmailsRepo repo.SuggestedEmailRepo
+	contactGroupRepo    repo.ContactGroupRepo
+	blockedUserRepo     repo.BlockedUserRepo
+	userRepo            repo.UserRepo
+}
+
+func NewContactService(suggestedEmailsRepo repo.SuggestedEmailRepo, contactGroupRepo repo.ContactGroupRepo, blockedUserRepo repo.BlockedUserRepo, userRepo repo.UserRepo) ContactService {
+	return &contactService{
+		suggestedEmailsRepo: suggestedEmailsRepo,
+		contactGroupRepo:    contactGroupRepo,
+		blockedUserRepo:     blockedUserRepo,
+		userRepo:            userRepo,
+	}
+}
+
+const (
+	contactGroupsLimit       = 20
+	contactGroupNameMaxSize  = 100
+	contactGroupMembersLimit = sharedPlanUsersLimit
+)
+
+// GetMany returns the user's contacts, the ones shared with most and most recently first
+func (s *contactService) GetMany(userID uuid.UUID) []SuggestedEmail {
+	return s.suggestedEmailsRepo.GetMany(userID)
+}
+
+func (s *contactService) Delete(userID uuid.UUID, contactID uuid.UUID) {
+	s.validateUserOwnsTheContact(userID, contactID)
+	s.suggestedEmailsRepo.Delete(contactID)
+}
+
+// GetGroups returns the user's groups by name, each with its contacts
+func (s *contactService) GetGroups(userID uuid.UUID) []ContactGroup {
+	groups := s.contactGroupRepo.GetMany(userID)
+	members := s.contactGroupRepo.GetMembers(userID)
+	for i := range groups {
+		groups[i].Contacts = []SuggestedEmail{}
+		for _, member := range members {
+			if member.GroupID == groups[i].ID {
+				groups[i].Contacts = append(groups[i].Contacts, member.SuggestedEmail)
+			}
+		}
+	}
+	return groups
+}
+
+func (s *contactService) CreateGroup(userID uuid.UUID, name string) uuid.UUID {
+	name = validateGroupName(name)
+	if s.contactGroupRepo.GetCount(userID) >= contactGroupsLimit {
+		panic(models.LogicError(fmt.Sprintf("maximum of %d groups reached", contactGroupsLimit), "max_groups_limit_reached"))
+	}
+	id := s.contactGroupRepo.Create(userID, name)
+	if id == uuid.Nil {
+		panic(models.LogicError("group name already exists", "group_name_exists"))
+	}
+	return id
+}
+
+func (s *contactService) RenameGroup(userID uuid.UUID, groupID uuid.UUID, name string) {
+	s.validateUserOwnsTheGroup(userID, groupID)
+	if s.contactGroupRepo.UpdateName(groupID, validateGroupName(name)) == 0 {
+		panic(models.LogicError("group name already exists", "group_name_exists"))
+	}
+}
+
+func (s *contactService) DeleteGroup(userID uuid.UUID, groupID uuid.UUID) {
+	s.validateUserOwnsTheGroup(userID, groupID)
+	s.contactGroupRepo.Delete(groupID)
+}
+
+func (s *contactService) AddToGroup(userID uuid.UUID, groupID, contactID uuid.UUID) {
+	s.validateUserOwnsTheGroup(userID, groupID)
+	s.validateUserOwnsTheContact(userID, contactID)
+	if s.contactGroupRepo.GetMembersCount(groupID) >= contactGroupMembersLimit {
+		panic(models.LogicError(fmt.Sprintf("maximum of %d contacts in a group reached", contactGroupMembersLimit), "max_group_contacts_reached"))
+	}
+	s.contactGroupRepo.AddMember(groupID, contactID)
+}
+
+func (s *contactService) RemoveFromGroup(userID uuid.UUID, groupID, contactID uuid.UUID) {
+	s.validateUserOwnsTheGroup(userID, groupID)
+	s.contactGroupRepo.RemoveMember(groupID, contactID)
+}
+
+func (s *contactService) GetBlocked(userID uuid.UUID) []BlockedUser {
+	return s.blockedUserRepo.GetMany(userID)
+}
+
+// Block stops the user with the email from sharing plans with the user, plans already shared stay
+func (s *contactService) Block(userID uuid.UUID, email string) {
+	user := s.userRepo.GetOneByEmail(email)
+	if user == nil {
+		panic(models.NotFoundError("email not found"))
+	}
+	if user.ID == userID {
+		panic(models.InputError("cannot block yourself"))
+	}
+	s.blockedUserRepo.Create(userID, user.ID)
+}
+
+func (s *contactService) Unblock(userID uuid.UUID, blockedUserID uuid.UUID) {
+	if s.blockedUserRepo.Delete(userID, blockedUserID) == 0 {
+		panic(models.NotFoundError("blocked user not found"))
+	}
+}
+
+func (s *contactService) validateUserOwnsTheGroup(userID, groupID uuid.UUID) *ContactGroup {
+	group := s.contactGroupRepo.GetOne(groupID)
+	if group == nil || group.UserID != userID {
+		panic(models.NotFoundError("group not found"))
+	}
+	return group
+}
+
+func (s *contactService) validateUserOwnsTheContact(userID, contactID uuid.UUID) {
+	contact := s.suggestedEmailsRepo.GetOne(contactID)
+	if contact == nil || contact.UserID != userID {
+		panic(models.NotFoundError("contact not found"))
+	}
+}
+
+func validateGroupName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > contactGroupNameMaxSize {
+		panic(models.InputError(fmt.Sprintf("name should be 1 to %d characters", contactGroupNameMaxSize)))
+	}
+	return name
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/export"
+
+	"github.com/google/uuid"
+)
+
+type ExportService interface {
+	Export(userID uuid.UUID, planID *uuid.UUID, format models.ExportFormat, w io.Writer)
+}
+
+type exportService struct {
+	planRepo        repo.PlanRepo
+	planMembersRepo repo.PlanMembersRepo
+	taskRepo        repo.TaskRepo
+}
+
+func NewExportService(planRepo repo.PlanRepo, planMembersRepo repo.PlanMembersRepo, taskRepo repo.TaskRepo) ExportService {
+	return &exportService{planRepo: planRepo, planMembersRepo: planMembersRepo, taskRepo: taskRepo}
+}
+
+// Export writes the user's owned and shared plans with their tasks to w, or only planID when given
+func (s *exportService) Export(userID uuid.UUID, planID *uuid.UUID, format models.ExportFormat, w io.Writer) {
+	if planID != nil {
+		plan := s.planRepo.GetOne(*planID)
+		if plan.ID == uuid.Nil {
+			panic(models.NotFoundError("plan not found"))
+		}
+		if plan.User.ID != userID && !s.planMembersRepo.Exists(*planID, userID) {
+			panic(models.ForbiddenError("user is not a member of this plan"))
+		}
+	}
+
+	writer := export.NewWriter(format, w)
+	var writeErr error
+	s.taskRepo.GetManyWithPlans(userID, planID, func(row models.PlanTaskRow) {
+		if writeErr == nil {
+			writeErr = writer.Write(row)
+		}
+	})
+	if writeErr == nil {
+		writeErr = writer.Close()
+	}
+	if writeErr != nil {
+		panic(models.ServerError("export failed: " + writeErr.Error()))
+	}
+}
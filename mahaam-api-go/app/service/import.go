@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/export"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type ImportService interface {
+	Import(userID uuid.UUID, format models.ExportFormat, r io.Reader, dryRun bool) models.ImportReport
+}
+
+type importService struct {
+	db               *repo.AppDB
+	planRepo         repo.PlanRepo
+	planCategoryRepo repo.PlanCategoryRepo
+	taskRepo         repo.TaskRepo
+}
+
+func NewImportService(db *repo.AppDB, planRepo repo.PlanRepo, planCategoryRepo repo.PlanCategoryRepo, taskRepo repo.TaskRepo) ImportService {
+	return &importService{db: db, planRepo: planRepo, planCategoryRepo: planCategoryRepo, taskRepo: taskRepo}
+}
+
+const (
+	planTitleMaxSize = 100
+	taskTitleMaxSize = 255
+)
+
+// Import creates the plans and tasks read from r in one transaction, nothing is created when any of them is invalid.
+// On a dry run the report lists what would be created and all validation errors, without creating anything.
+func (s *importService) Import(userID uuid.UUID, format models.ExportFormat, r io.Reader, dryRun bool) models.ImportReport {
+	plans, err := export.Parse(format, r)
+	if err != nil {
+		if dryRun {
+			return models.ImportReport{DryRun: true, Errors: []string{err.Error()}}
+		}
+		panic(models.InputError(err.Error()))
+	}
+
+	report := models.ImportReport{DryRun: dryRun}
+	errs := s.validate(userID, plans)
+	for _, plan := range plans {
+		report.Plans++
+		report.Tasks += len(plan.Tasks)
+	}
+	if len(plans) == 0 {
+		errs = append(errs, "no plans found to import")
+	}
+
+	if dryRun {
+		report.Errors = errs
+		return report
+	}
+	if len(errs) > 0 {
+		panic(models.InputError(strings.Join(errs, "; ")))
+	}
+
+	// plans and tasks are listed by sort order descending, so they are created in reverse to keep the file order
+	report.PlanIDs = make([]uuid.UUID, len(plans))
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		for i := len(plans) - 1; i >= 0; i-- {
+			report.PlanIDs[i] = s.createPlan(tx, userID, plans[i])
+		}
+		return nil
+	})
+	return report
+}
+
+func (s *importService) createPlan(tx *sqlx.Tx, userID uuid.UUID, plan models.ExportPlan) uuid.UUID {
+	id := s.planRepo.Create(tx, userID, PlanIn{Title: plan.Title, Starts: plan.Starts, Ends: plan.Ends}, string(models.PlanTypeMain))
+	for i := len(plan.Tasks) - 1; i >= 0; i-- {
+		s.taskRepo.CreateImported(tx, id, plan.Tasks[i])
+	}
+	s.planRepo.UpdateDonePercent(tx, id)
+
+	planType := s.planType(userID, plan)
+	if planType != string(models.PlanTypeMain) {
+		s.planRepo.RemoveFromOrder(tx, userID, id)
+		s.planRepo.UpdateType(tx, userID, id, planType)
+	}
+	if planType == string(models.PlanTypeArchived) {
+		s.planRepo.UpdateStatus(tx, id, models.PlanStatusArchived)
+	} else {
+		s.planRepo.SyncStatusWithTasks(tx, id)
+	}
+	return id
+}
+
+// planType keeps the imported type when it is built-in or one of the user's categories, otherwise the plan goes to Main.
+// Archived plans are imported as Archived whatever their type was.
+func (s *importService) planType(userID uuid.UUID, plan models.ExportPlan) string {
+	if strings.EqualFold(plan.Status, string(models.PlanStatusArchived)) {
+		return string(models.PlanTypeArchived)
+	}
+	if builtIn, ok := models.BuiltInPlanType(plan.Type); ok {
+		return string(builtIn)
+	}
+	if plan.Type != "" {
+		if category := s.planCategoryRepo.GetOneByName(userID, plan.Type); category != nil {
+			return category.Name
+		}
+	}
+	return string(models.PlanTypeMain)
+}
+
+func (s *importService) validate(userID uuid.UUID, plans []models.ExportPlan) []string {
+	errs := make([]string, 0)
+	newPlansCount := make(map[string]int)
+	for i, plan := range plans {
+		name := fmt.Sprintf("plan %d", i+1)
+		if plan.Title != nil {
+			name = fmt.Sprintf("plan %d (%s)", i+1, *plan.Title)
+			if utf8.RuneCountInString(*plan.Title) > planTitleMaxSize {
+				errs = append(errs, fmt.Sprintf("%s: title should be at most %d characters", name, planTitleMaxSize))
+			}
+		}
+		if !isImportDate(plan.Starts) || !isImportDate(plan.Ends) {
+			errs = append(errs, name+": starts and ends should be dates like 2024-12-31")
+		}
+		if len(plan.Tasks) > maxTasksLimit {
+			errs = append(errs, fmt.Sprintf("%s: has %d tasks, maximum is %d", name, len(plan.Tasks), maxTasksLimit))
+		}
+		for j, task := range plan.Tasks {
+			if strings.TrimSpace(task.Title) == "" {
+				errs = append(errs, fmt.Sprintf("%s: task %d has no title", name, j+1))
+			} else if utf8.RuneCountInString(task.Title) > taskTitleMaxSize {
+				errs = append(errs, fmt.Sprintf("%s: task %d title should be at most %d characters", name, j+1, taskTitleMaxSize))
+			}
+			if !isImportDate(task.Due) {
+				errs = append(errs, fmt.Sprintf("%s: task %d due should be a date like 2024-12-31", name, j+1))
+			}
+		}
+		newPlansCount[s.planType(userID, plan)]++
+	}
+
+	// plansLimit is of all the user's plans but archived ones, like when creating plans
+	count := 0
+	for planType, n := range newPlansCount {
+		if planType != string(models.PlanTypeArchived) {
+			count += n
+		}
+	}
+	if existing := s.planRepo.GetOwnedCount(userID); existing+int64(count) > plansLimit {
+		errs = append(errs, fmt.Sprintf("importing %d plans exceeds the maximum of %d plans, %d already exist", count, plansLimit, existing))
+	}
+	return errs
+}
+
+func isImportDate(value *string) bool {
+	if value == nil {
+		return true
+	}
+	_, err := time.Parse(time.DateOnly, *value)
+	return err == nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"mahaam-api/app/models"
+	"mahaam-api/utils/oidc"
+	token "mahaam-api/utils/token"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// oidcLoginExpiry is how long the user has to log in with the identity provider
+const oidcLoginExpiry = 10 * time.Minute
+
+// StartOidc starts an authorization code login with PKCE for the device, the code verifier never leaves the api
+func (s *userService) StartOidc(meta Meta, providerName string) *OidcAuthorization {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		panic(models.InputError("provider is not supported"))
+	}
+
+	state := oidc.NewCodeVerifier()
+	login := &models.OidcLogin{
+		StateHash:    token.HashOpaqueToken(state),
+		Provider:     providerName,
+		DeviceID:     meta.DeviceID,
+		CodeVerifier: oidc.NewCodeVerifier(),
+		Nonce:        oidc.NewCodeVerifier(),
+		ExpiresAt:    time.Now().Add(oidcLoginExpiry),
+	}
+	authorizationURL, err := provider.AuthorizationURL(state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		s.logger.Error(uuid.Nil, "Error starting %s login: %v", providerName, err)
+		panic(models.ServerError("identity provider is not available"))
+	}
+	s.oidcRepo.CreateLogin(login)
+	return &OidcAuthorization{AuthorizationURL: authorizationURL, State: state}
+}
+
+// VerifyOidc completes the login of the device with the code the identity provider redirected back with.
+// A user is found by its linked identity, then by the verified email, and the device's anonymous user is merged into it.
+func (s *userService) VerifyOidc(meta Meta, state, code string) *VerifiedUser {
+	login := s.oidcRepo.TakeLogin(token.HashOpaqueToken(state))
+	if login == nil || login.DeviceID != meta.DeviceID {
+		panic(models.LogicError("login expired, start it again", "invalid_oidc_state"))
+	}
+	provider, ok := s.oidcProviders[login.Provider]
+	if !ok {
+		panic(models.LogicError("login expired, start it again", "invalid_oidc_state"))
+	}
+
+	identity, err := provider.Exchange(code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		s.logger.Error(uuid.Nil, "Error verifying %s login: %v", login.Provider, err)
+		e := models.UnauthorizedError("identity provider login failed")
+		e.Key = "oidc_failed"
+		panic(e)
+	}
+
+	var user *User
+	if userID := s.oidcRepo.GetIdentityUserID(login.Provider, identity.Subject); userID != uuid.Nil {
+		user = s.userRepo.GetOne(userID)
+	}
+	email := strings.TrimSpace(identity.Email)
+	if user == nil {
+		if email == "" || !identity.EmailVerified {
+			panic(models.LogicError("the identity provider did not verify the email", "email_not_verified"))
+		}
+		user = s.userRepo.GetOneByEmail(email)
+	}
+	if user != nil && user.Email != nil {
+		email = *user.Email
+	}
+
+	link := func(tx *sqlx.Tx, userID uuid.UUID) {
+		s.oidcRepo.CreateIdentity(tx, login.Provider, identity.Subject, userID, identity.Email)
+	}
+	s.logger.Info(uuid.Nil, "OIDC login with %s for %s", login.Provider, email)
+	return s.login(meta, email, user, link)
+}
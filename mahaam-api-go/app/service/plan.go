@@ -1,9 +1,15 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"mahaam-api/app/models"
 	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
+	emails "mahaam-api/utils/email"
+	logs "mahaam-api/utils/log"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -12,38 +18,73 @@ import (
 type PlanService interface {
 	GetOne(planID uuid.UUID) *Plan
 	GetMany(userID uuid.UUID, planType string) []Plan
+	GetPage(userID uuid.UUID, planType string, q ListQuery) models.Page[Plan]
 	Create(userID uuid.UUID, plan PlanIn) uuid.UUID
 	Update(userID uuid.UUID, plan *PlanIn)
 	Delete(userID uuid.UUID, id uuid.UUID)
 	Share(userID uuid.UUID, id uuid.UUID, email string)
+	ShareWithGroup(userID uuid.UUID, id uuid.UUID, groupID uuid.UUID) *models.GroupShare
+	Invite(userID uuid.UUID, id uuid.UUID, email string)
 	Unshare(userID uuid.UUID, id uuid.UUID, email string)
 	Leave(userID uuid.UUID, id uuid.UUID)
 	UpdateType(userID uuid.UUID, id uuid.UUID, planType string)
 	ReOrder(userID uuid.UUID, planType string, oldOrder, newOrder int)
+	UpdateStatus(userID uuid.UUID, id uuid.UUID, status string)
+	Pin(userID uuid.UUID, id uuid.UUID)
+	Unpin(userID uuid.UUID, id uuid.UUID)
+	AutoArchive() int
+	StartAutoArchiving(ctx context.Context)
 	ValidateUserOwnsThePlan(userID uuid.UUID, planID uuid.UUID)
 }
 
 type planService struct {
 	planRepo            repo.PlanRepo
 	planMembersRepo     repo.PlanMembersRepo
+	planCategoryRepo    repo.PlanCategoryRepo
+	planPinsRepo        repo.PlanPinsRepo
 	userRepo            repo.UserRepo
 	suggestedEmailsRepo repo.SuggestedEmailRepo
+	contactGroupRepo    repo.ContactGroupRepo
+	blockedUserRepo     repo.BlockedUserRepo
+	preferencesRepo     repo.PreferencesRepo
+	inviteLimit         rateLimit
+	notifier            emails.Notifier
 	db                  *repo.AppDB
+	cfg                 *conf.Conf
+	logger              logs.Logger
 }
 
 func NewPlanService(
 	db *repo.AppDB,
 	planRepo repo.PlanRepo,
 	planMembersRepo repo.PlanMembersRepo,
+	planCategoryRepo repo.PlanCategoryRepo,
+	planPinsRepo repo.PlanPinsRepo,
 	userRepo repo.UserRepo,
-	suggestedEmailsRepo repo.SuggestedEmailRepo) PlanService {
+	suggestedEmailsRepo repo.SuggestedEmailRepo,
+	contactGroupRepo repo.ContactGroupRepo,
+	blockedUserRepo repo.BlockedUserRepo,
+	preferencesRepo repo.PreferencesRepo,
+	rateLimitRepo repo.RateLimitRepo,
+	notifier emails.Notifier,
+	cfg *conf.Conf,
+	logger logs.Logger) PlanService {
 
 	return &planService{
 		planRepo:            planRepo,
 		planMembersRepo:     planMembersRepo,
+		planCategoryRepo:    planCategoryRepo,
+		planPinsRepo:        planPinsRepo,
 		userRepo:            userRepo,
 		suggestedEmailsRepo: suggestedEmailsRepo,
+		contactGroupRepo:    contactGroupRepo,
+		blockedUserRepo:     blockedUserRepo,
+		preferencesRepo:     preferencesRepo,
+		inviteLimit:         newInviteRateLimit(rateLimitRepo),
+		notifier:            notifier,
 		db:                  db,
+		cfg:                 cfg,
+		logger:              logger,
 	}
 }
 
@@ -57,22 +98,61 @@ func (s *planService) GetOne(planID uuid.UUID) *Plan {
 }
 
 func (s *planService) GetMany(userID uuid.UUID, planType string) []Plan {
+	planType = s.validatePlanType(userID, planType)
 	plans := s.planRepo.GetMany(userID, planType)
-	sharedPlans := s.planMembersRepo.GetOtherPlans(userID)
-	return append(plans, sharedPlans...)
+	sharedPlans := s.planMembersRepo.GetOtherPlans(userID, planType)
+	plans = append(plans, sharedPlans...)
+	// owned and shared plans share one order per user, with pinned plans first
+	slices.SortStableFunc(plans, func(a, b Plan) int {
+		switch {
+		case a.IsPinned && !b.IsPinned:
+			return -1
+		case !a.IsPinned && b.IsPinned:
+			return 1
+		}
+		return b.SortOrder - a.SortOrder
+	})
+	return plans
+}
+
+// GetPage resolves the due filter to dates of the user's today and week
+func (s *planService) GetPage(userID uuid.UUID, planType string, q ListQuery) models.Page[Plan] {
+	planType = s.validatePlanType(userID, planType)
+	if q.Due != "" {
+		p := getPreferences(s.preferencesRepo, s.cfg, userID)
+		today, weekStart := p.Today(), p.WeekStartDate()
+		var from, before time.Time
+		switch q.Due {
+		case models.DueOverdue:
+			before = today
+		case models.DueToday:
+			from, before = today, today.AddDate(0, 0, 1)
+		case models.DueWeek:
+			from, before = weekStart, weekStart.AddDate(0, 0, 7)
+		}
+		if !from.IsZero() {
+			value := from.Format(time.DateOnly)
+			q.DueFrom = &value
+		}
+		value := before.Format(time.DateOnly)
+		q.DueBefore = &value
+	}
+	return s.planRepo.GetPage(userID, planType, q)
 }
 
 const plansLimit = 100
 
+// Create adds the plan with the user's default plan type. plansLimit is of all the user's plans but archived ones.
 func (s *planService) Create(userID uuid.UUID, plan PlanIn) uuid.UUID {
-	plansCount := s.planRepo.GetCount(userID, string(models.PlanTypeMain))
+	planType := getPreferences(s.preferencesRepo, s.cfg, userID).DefaultPlanType
+	plansCount := s.planRepo.GetOwnedCount(userID)
 	if plansCount >= plansLimit {
 		panic(models.LogicError("maximum plans limit reached", "max_plans_limit_reached"))
 	}
 
 	var planID uuid.UUID
 	err := repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
-		planID = s.planRepo.Create(tx, userID, plan)
+		planID = s.planRepo.Create(tx, userID, plan, planType)
 		return nil
 	})
 
@@ -91,11 +171,14 @@ func (s *planService) Delete(userID uuid.UUID, id uuid.UUID) {
 	s.ValidateUserOwnsThePlan(userID, id)
 	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
 		s.planRepo.RemoveFromOrder(tx, userID, id)
+		s.planMembersRepo.RemoveFromMembersOrder(tx, id)
 		s.planRepo.Delete(tx, id)
 		return nil
 	})
 }
 
+const sharedPlanUsersLimit = 20
+
 func (s *planService) Share(userID uuid.UUID, id uuid.UUID, email string) {
 	s.ValidateUserOwnsThePlan(userID, id)
 	user := s.userRepo.GetOneByEmail(email)
@@ -105,8 +188,10 @@ func (s *planService) Share(userID uuid.UUID, id uuid.UUID, email string) {
 	if user.ID == userID {
 		panic(models.LogicError("not allowed to share with creator", "not_allowed_to_share_with_creator"))
 	}
+	if s.blockedUserRepo.Exists(user.ID, userID) {
+		panic(models.LogicError("user does not accept plans from you", "sharing_blocked"))
+	}
 
-	const sharedPlanUsersLimit = 20
 	plan := s.planRepo.GetOne(id)
 	if plan.IsShared {
 		sharedUsersCount := s.planMembersRepo.GetUsersCount(id)
@@ -114,19 +199,108 @@ func (s *planService) Share(userID uuid.UUID, id uuid.UUID, email string) {
 			panic(models.LogicError("maximum of 20 shares reached", "max_is_20"))
 		}
 	} else {
-		count := s.planMembersRepo.GetPlansCount(userID)
-		if count >= sharedPlanUsersLimit {
-			panic(models.LogicError("maximum of 20 shares reached", "max_is_20"))
+		s.validateSharedPlansLimit(userID)
+	}
+
+	s.addMember(s.userRepo.GetOne(userID), plan, user, email)
+}
+
+// ShareWithGroup shares the plan with every contact of the group who can be added,
+// the others are skipped: no account, already members, blocking the sharer, or over the shares limit
+func (s *planService) ShareWithGroup(userID uuid.UUID, id uuid.UUID, groupID uuid.UUID) *models.GroupShare {
+	s.ValidateUserOwnsThePlan(userID, id)
+	group := s.contactGroupRepo.GetOne(groupID)
+	if group == nil || group.UserID != userID {
+		panic(models.NotFoundError("group not found"))
+	}
+
+	plan := s.planRepo.GetOne(id)
+	if !plan.IsShared {
+		s.validateSharedPlansLimit(userID)
+	}
+	sharedUsersCount := s.planMembersRepo.GetUsersCount(id)
+	creator := s.userRepo.GetOne(userID)
+
+	members := s.contactGroupRepo.GetGroupMembers(groupID)
+	emails := []string{}
+	for _, member := range members {
+		if member.Email != nil {
+			emails = append(emails, *member.Email)
 		}
 	}
+	users := map[string]*User{}
+	for _, user := range s.userRepo.GetManyByEmails(emails) {
+		users[*user.Email] = &user
+	}
 
-	s.planMembersRepo.Create(id, user.ID)
+	result := &models.GroupShare{Shared: []string{}, Skipped: []string{}}
+	for _, email := range emails {
+		user := users[email]
+		if user == nil || user.ID == userID || sharedUsersCount >= sharedPlanUsersLimit ||
+			s.planMembersRepo.Exists(id, user.ID) || s.blockedUserRepo.Exists(user.ID, userID) {
+			result.Skipped = append(result.Skipped, email)
+			continue
+		}
+		s.addMember(creator, plan, user, email)
+		sharedUsersCount++
+		result.Shared = append(result.Shared, email)
+	}
+	return result
+}
+
+func (s *planService) validateSharedPlansLimit(userID uuid.UUID) {
+	count := s.planMembersRepo.GetPlansCount(userID)
+	if count >= sharedPlanUsersLimit {
+		panic(models.LogicError("maximum of 20 shares reached", "max_is_20"))
+	}
+}
+
+func (s *planService) addMember(creator *User, plan *Plan, user *User, email string) {
+	s.planMembersRepo.Create(plan.ID, user.ID)
 	// No transaction needed for suggested emails, as it's just a suggestion
-	s.suggestedEmailsRepo.Create(userID, email)
-	creator := s.userRepo.GetOne(userID)
+	s.suggestedEmailsRepo.Create(creator.ID, email)
 	if creator.Email != nil {
 		s.suggestedEmailsRepo.Create(user.ID, *creator.Email)
 	}
+	s.notifier.Notify(email, emails.TemplatePlanShared, "", map[string]any{
+		"sharer":    displayName(creator),
+		"planTitle": planTitle(plan),
+	})
+}
+
+// Invite emails an invitation to join Mahaam, so the plan can be shared with the email after. It is the same
+// whether the email has an account or not, so it cannot tell who uses Mahaam, and is limited per user per day.
+func (s *planService) Invite(userID uuid.UUID, id uuid.UUID, email string) {
+	s.ValidateUserOwnsThePlan(userID, id)
+	creator := s.userRepo.GetOne(userID)
+	if creator.Email == nil {
+		panic(models.LogicError("log in with your email to invite others", "email_required"))
+	}
+	s.inviteLimit.take(inviteKey(userID.String()))
+	plan := s.planRepo.GetOne(id)
+	s.suggestedEmailsRepo.Create(userID, email)
+	s.notifier.Notify(email, emails.TemplatePlanInvitation, "", map[string]any{
+		"sharer":    displayName(creator),
+		"planTitle": planTitle(plan),
+	})
+}
+
+// displayName is how a user is shown to others in emails, the name when set or the email
+func displayName(user *User) string {
+	if user.Name != nil && *user.Name != "" {
+		return *user.Name
+	}
+	if user.Email != nil {
+		return *user.Email
+	}
+	return "Someone"
+}
+
+func planTitle(plan *Plan) string {
+	if plan.Title != nil && *plan.Title != "" {
+		return *plan.Title
+	}
+	return "Untitled"
 }
 
 func (s *planService) Unshare(userID uuid.UUID, id uuid.UUID, email string) {
@@ -135,37 +309,217 @@ func (s *planService) Unshare(userID uuid.UUID, id uuid.UUID, email string) {
 	if user == nil {
 		panic(models.NotFoundError("email not found"))
 	}
-	s.planMembersRepo.Delete(id, user.ID)
+	s.removeMember(id, user.ID)
+	s.planPinsRepo.Delete(id, user.ID)
 }
 
 // Leave allows a user to leave a shared plan
 func (s *planService) Leave(userID uuid.UUID, id uuid.UUID) {
-	rows := s.planMembersRepo.Delete(id, userID)
+	rows := s.removeMember(id, userID)
 	if rows != 1 {
 		panic(models.LogicError(fmt.Sprintf("user cannot leave plan: userId=%s, planId=%s", userID, id), "user_cannot_leave_plan"))
 	}
+	s.planPinsRepo.Delete(id, userID)
 }
 
+func (s *planService) removeMember(planID, userID uuid.UUID) int64 {
+	var rows int64
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		s.planMembersRepo.RemoveFromOrder(tx, planID, userID)
+		rows = s.planMembersRepo.Delete(tx, planID, userID)
+		return nil
+	})
+	return rows
+}
+
+const pinsLimit = 10
+
+// Pin puts an owned or shared plan at the top of the user's lists, pins are private to each user
+func (s *planService) Pin(userID uuid.UUID, id uuid.UUID) {
+	s.validateUserCanAccessThePlan(userID, id)
+	if s.planPinsRepo.GetCount(userID) >= pinsLimit {
+		panic(models.LogicError(fmt.Sprintf("maximum of %d pins reached", pinsLimit), "max_pins_limit_reached"))
+	}
+	s.planPinsRepo.Create(id, userID)
+}
+
+func (s *planService) Unpin(userID uuid.UUID, id uuid.UUID) {
+	s.planPinsRepo.Delete(id, userID)
+}
+
+// UpdateType moves the plan to another list of the user, for a shared plan only the member's list changes
 func (s *planService) UpdateType(userID uuid.UUID, id uuid.UUID, planType string) {
-	s.ValidateUserOwnsThePlan(userID, id)
-	count := s.planRepo.GetCount(userID, planType)
-	if count >= 100 {
-		panic(models.LogicError("maximum of 100 plans reached", "max_is_100"))
+	plan := s.planRepo.GetOne(id)
+	if plan.ID == uuid.Nil {
+		panic(models.NotFoundError("plan not found"))
+	}
+	isMember := plan.User.ID != userID
+	if isMember && !s.planMembersRepo.Exists(id, userID) {
+		panic(models.ForbiddenError("user is not a member of this plan"))
+	}
+
+	planType = s.validatePlanType(userID, planType)
+	if isMember {
+		if s.planRepo.GetCount(userID, planType) >= plansLimit {
+			panic(models.LogicError("maximum of 100 plans reached", "max_is_100"))
+		}
+	} else if *plan.Type == string(models.PlanTypeArchived) && planType != string(models.PlanTypeArchived) {
+		// plansLimit is of all the user's plans but archived ones, so only moving an own plan out of Archived counts
+		if s.planRepo.GetOwnedCount(userID) >= plansLimit {
+			panic(models.LogicError("maximum of 100 plans reached", "max_is_100"))
+		}
+	}
+
+	if isMember {
+		repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+			s.planMembersRepo.RemoveFromOrder(tx, id, userID)
+			s.planMembersRepo.UpdateType(tx, id, userID, planType)
+			return nil
+		})
+		return
 	}
 
 	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
 		s.planRepo.RemoveFromOrder(tx, userID, id)
 		s.planRepo.UpdateType(tx, userID, id, planType)
+		// moving in or out of Archived archives or reopens the plan
+		if planType == string(models.PlanTypeArchived) {
+			s.planRepo.UpdateStatus(tx, id, models.PlanStatusArchived)
+		} else if s.planRepo.UpdateStatus(tx, id, models.PlanStatusOpen) == 1 {
+			s.planRepo.SyncStatusWithTasks(tx, id)
+		}
 		return nil
 	})
 }
 
 func (s *planService) ReOrder(userID uuid.UUID, planType string, oldOrder, newOrder int) {
+	planType = s.validatePlanType(userID, planType)
 	count := s.planRepo.GetCount(userID, planType)
 	if oldOrder > int(count) || newOrder > int(count) {
 		panic(models.InputError(fmt.Sprintf("oldOrder and newOrder should be less than %d", count)))
 	}
-	s.planRepo.UpdateOrder(userID, planType, oldOrder, newOrder)
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		s.planRepo.UpdateOrder(tx, userID, planType, oldOrder, newOrder)
+		return nil
+	})
+}
+
+// UpdateStatus moves the plan along its lifecycle, archiving moves it to the Archived type and reopening moves it back to Main
+func (s *planService) UpdateStatus(userID uuid.UUID, id uuid.UUID, status string) {
+	s.ValidateUserOwnsThePlan(userID, id)
+	plan := s.planRepo.GetOne(id)
+	current := models.PlanStatus(*plan.Status)
+	next := models.PlanStatus(status)
+	if _, ok := models.PlanStatusTransitions[next]; !ok {
+		panic(models.InputError("Invalid plan status"))
+	}
+	if !slices.Contains(models.PlanStatusTransitions[current], next) {
+		panic(models.LogicError(fmt.Sprintf("plan cannot move from %s to %s", current, next), "invalid_status_transition"))
+	}
+
+	if next == models.PlanStatusArchived {
+		s.archive(plan)
+		return
+	}
+
+	if current == models.PlanStatusArchived {
+		if *plan.Type == string(models.PlanTypeArchived) && s.planRepo.GetOwnedCount(userID) >= plansLimit {
+			panic(models.LogicError("maximum plans limit reached", "max_plans_limit_reached"))
+		}
+		repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+			s.planRepo.RemoveFromOrder(tx, userID, id)
+			s.planRepo.UpdateType(tx, userID, id, string(models.PlanTypeMain))
+			s.planRepo.UpdateStatus(tx, id, next)
+			s.planRepo.SyncStatusWithTasks(tx, id)
+			return nil
+		})
+		return
+	}
+
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		s.planRepo.UpdateStatus(tx, id, next)
+		return nil
+	})
+}
+
+// archive sets the plan status to Archived and moves it to the Archived type.
+// The status update is done first, so a plan archived meanwhile by another node is skipped.
+func (s *planService) archive(plan *Plan) bool {
+	archived := false
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		if s.planRepo.UpdateStatus(tx, plan.ID, models.PlanStatusArchived) == 0 {
+			return nil
+		}
+		if plan.Type == nil || *plan.Type != string(models.PlanTypeArchived) {
+			s.planRepo.RemoveFromOrder(tx, plan.User.ID, plan.ID)
+			s.planRepo.UpdateType(tx, plan.User.ID, plan.ID, string(models.PlanTypeArchived))
+		}
+		archived = true
+		return nil
+	})
+	return archived
+}
+
+const autoArchiveBatchSize = 100
+
+// AutoArchive archives plans whose ends date passed by more than the configured grace days
+func (s *planService) AutoArchive() int {
+	archivedCount := 0
+	for {
+		plans := s.planRepo.GetEndedBefore(s.cfg.AutoArchiveGraceDays, autoArchiveBatchSize)
+		batchCount := 0
+		for i := range plans {
+			if s.archive(&plans[i]) {
+				batchCount++
+			}
+		}
+		archivedCount += batchCount
+		if len(plans) < autoArchiveBatchSize || batchCount == 0 {
+			return archivedCount
+		}
+	}
+}
+
+func (s *planService) StartAutoArchiving(ctx context.Context) {
+	if !s.cfg.AutoArchiveEnabled {
+		return
+	}
+	go s.startAutoArchiving(ctx)
+}
+
+func (s *planService) startAutoArchiving(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runAutoArchive()
+		}
+	}
+}
+
+func (s *planService) runAutoArchive() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Auto archiving failed: %v", r)
+		}
+	}()
+	if count := s.AutoArchive(); count > 0 {
+		s.logger.Info(uuid.Nil, "Auto archived %d plans", count)
+	}
+}
+
+func (s *planService) validateUserCanAccessThePlan(userID uuid.UUID, planID uuid.UUID) {
+	plan := s.planRepo.GetOne(planID)
+	if plan.ID == uuid.Nil {
+		panic(models.NotFoundError("plan not found"))
+	}
+	if plan.User.ID != userID && !s.planMembersRepo.Exists(planID, userID) {
+		panic(models.ForbiddenError("user is not a member of this plan"))
+	}
 }
 
 func (s *planService) ValidateUserOwnsThePlan(userID uuid.UUID, planID uuid.UUID) {
@@ -178,3 +532,15 @@ func (s *planService) ValidateUserOwnsThePlan(userID uuid.UUID, planID uuid.UUID
 		panic(models.ForbiddenError("user does not own this plan"))
 	}
 }
+
+// validatePlanType accepts a built-in type or one of the user's categories, and returns it as stored
+func (s *planService) validatePlanType(userID uuid.UUID, planType string) string {
+	if builtIn, ok := models.BuiltInPlanType(planType); ok {
+		return string(builtIn)
+	}
+	category := s.planCategoryRepo.GetOneByName(userID, planType)
+	if category == nil {
+		panic(models.InputError("Invalid plan type"))
+	}
+	return category.Name
+}
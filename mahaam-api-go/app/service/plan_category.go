@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PlanCategoryService interface {
+	GetMany(userID uuid.UUID) []PlanCategory
+	Create(userID uuid.UUID, name string) uuid.UUID
+	Rename(userID uuid.UUID, id uuid.UUID, name string)
+	Delete(userID uuid.UUID, id uuid.UUID)
+	ReOrder(userID uuid.UUID, oldOrder, newOrder int)
+}
+
+type planCategoryService struct {
+	planCategoryRepo repo.PlanCategoryRepo
+	planRepo         repo.PlanRepo
+	preferencesRepo  repo.PreferencesRepo
+	db               *repo.AppDB
+}
+
+func NewPlanCategoryService(db *repo.AppDB, planCategoryRepo repo.PlanCategoryRepo, planRepo repo.PlanRepo, preferencesRepo repo.PreferencesRepo) PlanCategoryService {
+	return &planCategoryService{
+		planCategoryRepo: planCategoryRepo,
+		planRepo:         planRepo,
+		preferencesRepo:  preferencesRepo,
+		db:               db,
+	}
+}
+
+const (
+	categoriesLimit     = 20
+	categoryNameMaxSize = 50
+)
+
+// GetMany lists Main first, then the user's own categories in their order, then Archived
+func (s *planCategoryService) GetMany(userID uuid.UUID) []PlanCategory {
+	categories := []PlanCategory{{Name: string(models.PlanTypeMain), IsBuiltIn: true}}
+	categories = append(categories, s.planCategoryRepo.GetMany(userID)...)
+	return append(categories, PlanCategory{Name: string(models.PlanTypeArchived), IsBuiltIn: true})
+}
+
+func (s *planCategoryService) Create(userID uuid.UUID, name string) uuid.UUID {
+	name = s.validateName(userID, name)
+	if s.planCategoryRepo.GetCount(userID) >= categoriesLimit {
+		panic(models.LogicError(fmt.Sprintf("maximum of %d categories reached", categoriesLimit), "max_categories_limit_reached"))
+	}
+	return s.planCategoryRepo.Create(userID, name)
+}
+
+func (s *planCategoryService) Rename(userID uuid.UUID, id uuid.UUID, name string) {
+	category := s.validateUserOwnsTheCategory(userID, id)
+	name = strings.TrimSpace(name)
+	// changing the letter case only cannot conflict with another category
+	if !strings.EqualFold(category.Name, name) {
+		name = s.validateName(userID, name)
+	}
+
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		s.planCategoryRepo.UpdateName(tx, id, name)
+		s.planRepo.RenameType(tx, userID, category.Name, name)
+		s.preferencesRepo.UpdateDefaultPlanType(tx, userID, category.Name, name)
+		return nil
+	})
+}
+
+func (s *planCategoryService) Delete(userID uuid.UUID, id uuid.UUID) {
+	category := s.validateUserOwnsTheCategory(userID, id)
+	if s.planRepo.GetCount(userID, category.Name) > 0 {
+		panic(models.LogicError("category has plans, move them first", "category_not_empty"))
+	}
+
+	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		s.planCategoryRepo.RemoveFromOrder(tx, userID, id)
+		s.planCategoryRepo.Delete(tx, id)
+		// new plans go to Main once their default category is deleted
+		s.preferencesRepo.UpdateDefaultPlanType(tx, userID, category.Name, string(models.PlanTypeMain))
+		return nil
+	})
+}
+
+func (s *planCategoryService) ReOrder(userID uuid.UUID, oldOrder, newOrder int) {
+	count := s.planCategoryRepo.GetCount(userID)
+	if oldOrder >= int(count) || newOrder >= int(count) {
+		panic(models.InputError(fmt.Sprintf("oldOrder and newOrder should be less than %d", count)))
+	}
+	s.planCategoryRepo.UpdateOrder(userID, oldOrder, newOrder)
+}
+
+func (s *planCategoryService) validateName(userID uuid.UUID, name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > categoryNameMaxSize {
+		panic(models.InputError(fmt.Sprintf("name should be 1 to %d characters", categoryNameMaxSize)))
+	}
+	if _, ok := models.BuiltInPlanType(name); ok {
+		panic(models.LogicError("name is reserved for a built-in type", "category_name_reserved"))
+	}
+	if s.planCategoryRepo.GetOneByName(userID, name) != nil {
+		panic(models.LogicError("category already exists", "category_already_exists"))
+	}
+	return name
+}
+
+func (s *planCategoryService) validateUserOwnsTheCategory(userID uuid.UUID, id uuid.UUID) *PlanCategory {
+	category := s.planCategoryRepo.GetOne(id)
+	if category == nil {
+		panic(models.NotFoundError("category not found"))
+	}
+	if category.UserID != userID {
+		panic(models.ForbiddenError("user does not own this category"))
+	}
+	return category
+}
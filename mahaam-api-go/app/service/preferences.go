@@ -0,0 +1,85 @@
+package service
+
+import (
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezones are validated on images without a tz database too
+
+	"github.com/google/uuid"
+)
+
+type PreferencesService interface {
+	Get(userID uuid.UUID) Preferences
+	Update(userID uuid.UUID, in PreferencesIn) Preferences
+}
+
+type preferencesService struct {
+	preferencesRepo  repo.PreferencesRepo
+	planCategoryRepo repo.PlanCategoryRepo
+	cfg              *conf.Conf
+}
+
+func NewPreferencesService(preferencesRepo repo.PreferencesRepo, planCategoryRepo repo.PlanCategoryRepo, cfg *conf.Conf) PreferencesService {
+	return &preferencesService{preferencesRepo: preferencesRepo, planCategoryRepo: planCategoryRepo, cfg: cfg}
+}
+
+func (s *preferencesService) Get(userID uuid.UUID) Preferences {
+	return getPreferences(s.preferencesRepo, s.cfg, userID)
+}
+
+// Update validates and saves the given preferences, keeping the others
+func (s *preferencesService) Update(userID uuid.UUID, in PreferencesIn) Preferences {
+	p := s.Get(userID)
+	if in.Timezone != nil {
+		if _, err := time.LoadLocation(*in.Timezone); err != nil || *in.Timezone == "" || *in.Timezone == "Local" {
+			panic(models.InputError("timezone is not valid"))
+		}
+		p.Timezone = *in.Timezone
+	}
+	if in.Locale != nil {
+		p.Locale = *in.Locale
+	}
+	if in.WeekStart != nil {
+		p.WeekStart = *in.WeekStart
+	}
+	if in.DefaultPlanType != nil {
+		p.DefaultPlanType = s.validPlanType(userID, *in.DefaultPlanType)
+	}
+	if in.NotifyPlanShared != nil {
+		p.NotifyPlanShared = *in.NotifyPlanShared
+	}
+	if in.NotifyTakeoutReady != nil {
+		p.NotifyTakeoutReady = *in.NotifyTakeoutReady
+	}
+	if in.Theme != nil {
+		p.Theme = *in.Theme
+	}
+	s.preferencesRepo.Upsert(&p)
+	return s.Get(userID)
+}
+
+// validPlanType accepts Main or one of the user's plan categories, new plans are not created archived
+func (s *preferencesService) validPlanType(userID uuid.UUID, planType string) string {
+	if builtIn, ok := models.BuiltInPlanType(planType); ok {
+		if builtIn == models.PlanTypeArchived {
+			panic(models.InputError("defaultPlanType can not be " + string(models.PlanTypeArchived)))
+		}
+		return string(builtIn)
+	}
+	category := s.planCategoryRepo.GetOneByName(userID, strings.TrimSpace(planType))
+	if category == nil {
+		panic(models.InputError("defaultPlanType is not a plan category"))
+	}
+	return category.Name
+}
+
+// getPreferences returns the user's preferences or the defaults, shared by services with date-sensitive features
+func getPreferences(preferencesRepo repo.PreferencesRepo, cfg *conf.Conf, userID uuid.UUID) Preferences {
+	if p := preferencesRepo.GetOne(userID); p != nil {
+		return *p
+	}
+	return models.DefaultPreferences(userID, cfg.MailDefaultLocale)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+type SearchService interface {
+	Search(userID uuid.UUID, q string) []SearchResult
+}
+
+type searchService struct {
+	planRepo repo.PlanRepo
+	taskRepo repo.TaskRepo
+}
+
+func NewSearchService(planRepo repo.PlanRepo, taskRepo repo.TaskRepo) SearchService {
+	return &searchService{planRepo: planRepo, taskRepo: taskRepo}
+}
+
+const (
+	searchMinLength = 2
+	searchMaxTerms  = 10
+	searchHitsLimit = 100
+)
+
+// Search finds owned and shared plans and tasks whose titles match every word of q as a prefix,
+// grouped by plan and best ranked first
+func (s *searchService) Search(userID uuid.UUID, q string) []SearchResult {
+	if len([]rune(strings.TrimSpace(q))) < searchMinLength {
+		panic(models.InputError(fmt.Sprintf("q should be at least %d characters", searchMinLength)))
+	}
+	tsQuery := toPrefixTsQuery(q)
+	if tsQuery == "" {
+		panic(models.InputError("q should contain at least one word"))
+	}
+
+	planHits := s.planRepo.Search(userID, tsQuery, searchHitsLimit)
+	taskHits := s.taskRepo.Search(userID, tsQuery, searchHitsLimit)
+
+	results := make([]SearchResult, 0)
+	index := make(map[uuid.UUID]int)
+	resultOf := func(planID uuid.UUID, title *string, planType string, isShared bool) *SearchResult {
+		if i, ok := index[planID]; ok {
+			return &results[i]
+		}
+		index[planID] = len(results)
+		results = append(results, SearchResult{PlanID: planID, Title: title, Type: planType, IsShared: isShared, Tasks: []models.TaskSearchOut{}})
+		return &results[len(results)-1]
+	}
+
+	for _, hit := range planHits {
+		result := resultOf(hit.PlanID, hit.Title, hit.Type, hit.IsShared)
+		snippet := highlight(hit.Snippet)
+		result.Snippet = &snippet
+		result.Rank = max(result.Rank, hit.Rank)
+	}
+	for _, hit := range taskHits {
+		result := resultOf(hit.PlanID, hit.PlanTitle, hit.PlanType, hit.IsShared)
+		result.Tasks = append(result.Tasks, models.TaskSearchOut{ID: hit.TaskID, Done: hit.Done, Snippet: highlight(hit.Snippet), Rank: hit.Rank})
+		result.Rank = max(result.Rank, hit.Rank)
+	}
+
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		switch {
+		case a.Rank > b.Rank:
+			return -1
+		case a.Rank < b.Rank:
+			return 1
+		}
+		return 0
+	})
+	return results
+}
+
+// highlight escapes the snippet text, which is user input, then wraps the matched terms in <mark> tags
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, repo.SearchMatchStart, "<mark>")
+	return strings.ReplaceAll(snippet, repo.SearchMatchStop, "</mark>")
+}
+
+// toPrefixTsQuery turns free text into a tsquery requiring every word as a prefix, eg: "buy mil" => "buy:* & mil:*".
+// Only letters and digits are kept so user input cannot inject tsquery operators.
+func toPrefixTsQuery(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > searchMaxTerms {
+		words = words[:searchMaxTerms]
+	}
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
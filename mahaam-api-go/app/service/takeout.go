@@ -0,0 +1,295 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mahaam-api/app/models"
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
+	emails "mahaam-api/utils/email"
+	logs "mahaam-api/utils/log"
+	token "mahaam-api/utils/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TakeoutService interface {
+	Request(userID uuid.UUID) *TakeoutJob
+	Get(userID, id uuid.UUID) *TakeoutJob
+	Download(id uuid.UUID, expires, signature string) string
+	StartTakeoutJobs(ctx context.Context)
+}
+
+type takeoutService struct {
+	takeoutRepo         repo.TakeoutRepo
+	deviceRepo          repo.DeviceRepo
+	suggestedEmailsRepo repo.SuggestedEmailRepo
+	planCategoryRepo    repo.PlanCategoryRepo
+	preferencesRepo     repo.PreferencesRepo
+	notifier            emails.Notifier
+	cfg                 *conf.Conf
+	logger              logs.Logger
+	dir                 string
+	// wake asks the worker to claim jobs now instead of on its next tick
+	wake chan struct{}
+}
+
+func NewTakeoutService(takeoutRepo repo.TakeoutRepo,
+	deviceRepo repo.DeviceRepo,
+	suggestedEmailsRepo repo.SuggestedEmailRepo,
+	planCategoryRepo repo.PlanCategoryRepo,
+	preferencesRepo repo.PreferencesRepo,
+	notifier emails.Notifier,
+	cfg *conf.Conf,
+	logger logs.Logger,
+) TakeoutService {
+	// download links are signed with it, an empty key would let anyone knowing a job id sign one
+	if cfg.TokenSecretKey == "" {
+		log.Fatal("tokenSecretKey is required to sign takeout download links")
+	}
+	dir := cfg.TakeoutDir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "mahaam-takeouts")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Fatal("Error creating takeout dir: " + err.Error())
+	}
+	return &takeoutService{
+		takeoutRepo:         takeoutRepo,
+		deviceRepo:          deviceRepo,
+		suggestedEmailsRepo: suggestedEmailsRepo,
+		planCategoryRepo:    planCategoryRepo,
+		preferencesRepo:     preferencesRepo,
+		notifier:            notifier,
+		cfg:                 cfg,
+		logger:              logger,
+		dir:                 dir,
+		wake:                make(chan struct{}, 1),
+	}
+}
+
+const (
+	takeoutRetention    = 24 * time.Hour
+	takeoutLinkDuration = 15 * time.Minute
+	// a running job renews its lease every heartbeat, one not renewed for the lease is claimed again
+	takeoutHeartbeat = 30 * time.Second
+	takeoutLease     = 2 * time.Minute
+)
+
+// Request queues a takeout job, or returns the user's job still pending or running
+func (s *takeoutService) Request(userID uuid.UUID) *TakeoutJob {
+	if job := s.takeoutRepo.GetActive(userID); job != nil {
+		return job
+	}
+	id := s.takeoutRepo.Create(userID)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return s.takeoutRepo.GetOne(id)
+}
+
+// Get returns the job status, with a freshly signed download url once it is done
+func (s *takeoutService) Get(userID, id uuid.UUID) *TakeoutJob {
+	job := s.takeoutRepo.GetOne(id)
+	if job == nil || job.UserID != userID {
+		panic(models.NotFoundError("takeout not found"))
+	}
+	if job.Status == models.TakeoutStatusDone && job.ExpiresAt != nil && job.ExpiresAt.After(time.Now()) {
+		expires := strconv.FormatInt(time.Now().Add(takeoutLinkDuration).Unix(), 10)
+		url := fmt.Sprintf("/mahaam-api/takeout/%s?expires=%s&signature=%s", id, expires, token.Sign(s.cfg.TokenSecretKey, takeoutLinkValue(id, expires)))
+		job.DownloadURL = &url
+	}
+	return job
+}
+
+// Download returns the zip file path when the url signature is valid and not expired
+func (s *takeoutService) Download(id uuid.UUID, expires, signature string) string {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !token.VerifySignature(s.cfg.TokenSecretKey, takeoutLinkValue(id, expires), signature) {
+		panic(models.ForbiddenError("invalid download link"))
+	}
+	if time.Now().Unix() > expiresAt {
+		panic(models.ForbiddenError("download link expired"))
+	}
+	if !s.takeoutRepo.IsDownloadable(id) {
+		panic(models.NotFoundError("takeout not found"))
+	}
+	path := s.zipPath(id)
+	if _, err := os.Stat(path); err != nil {
+		panic(models.NotFoundError("takeout not found"))
+	}
+	return path
+}
+
+// takeoutLinkValue is what the download url signature covers
+func takeoutLinkValue(id uuid.UUID, expires string) string {
+	return id.String() + ":" + expires
+}
+
+func (s *takeoutService) StartTakeoutJobs(ctx context.Context) {
+	go s.startTakeoutJobs(ctx)
+}
+
+// startTakeoutJobs is the node's single takeout worker. It runs jobs requested on this node right away,
+// and each minute picks up jobs queued on other nodes or left by stopped ones, and removes expired zips.
+func (s *takeoutService) startTakeoutJobs(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+			s.runTakeoutJobs(ctx)
+		case <-ticker.C:
+			s.runTakeoutJobs(ctx)
+			s.deleteExpiredTakeouts()
+		}
+	}
+}
+
+func (s *takeoutService) runTakeoutJobs(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Takeout jobs failed: %v", r)
+		}
+	}()
+	for ctx.Err() == nil {
+		job := s.takeoutRepo.ClaimNext(time.Now().Add(-takeoutLease))
+		if job == nil {
+			return
+		}
+		s.runTakeout(ctx, job)
+	}
+}
+
+func (s *takeoutService) runTakeout(ctx context.Context, job *TakeoutJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Takeout %s failed: %v", job.ID, r)
+			os.Remove(s.zipPath(job.ID))
+			s.takeoutRepo.Fail(job.ID, "takeout failed, please request it again")
+		}
+	}()
+	stop := s.keepLease(ctx, job.ID)
+	err := s.writeZip(job.UserID, s.zipPath(job.ID))
+	stop()
+	if err != nil {
+		panic(err)
+	}
+	expiresAt := time.Now().Add(takeoutRetention)
+	s.takeoutRepo.Complete(job.ID, expiresAt)
+	s.logger.Info(uuid.Nil, "Takeout %s done for user %s", job.ID, job.UserID)
+	if profile := s.takeoutRepo.GetProfile(job.UserID); profile.Email != nil {
+		location := getPreferences(s.preferencesRepo, s.cfg, job.UserID).Location()
+		s.notifier.Notify(*profile.Email, emails.TemplateTakeoutReady, "", map[string]any{"expiresAt": expiresAt.In(location).Format(time.DateTime + " MST")})
+	}
+}
+
+// keepLease renews the job's lease until the returned stop is called, so other nodes do not claim it meanwhile
+func (s *takeoutService) keepLease(ctx context.Context, id uuid.UUID) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(takeoutHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.renewLease(id)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *takeoutService) renewLease(id uuid.UUID) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Renewing takeout %s lease failed: %v", id, r)
+		}
+	}()
+	s.takeoutRepo.Heartbeat(id)
+}
+
+func (s *takeoutService) deleteExpiredTakeouts() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Deleting expired takeouts failed: %v", r)
+		}
+	}()
+	for _, id := range s.takeoutRepo.DeleteExpired() {
+		if err := os.Remove(s.zipPath(id)); err != nil && !os.IsNotExist(err) {
+			s.logger.Error(uuid.Nil, "Deleting takeout %s zip failed: %v", id, err)
+		}
+	}
+}
+
+// zipPath is where the job's zip is stored, the takeout dir is shared by all nodes
+func (s *takeoutService) zipPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, id.String()+".zip")
+}
+
+// writeZip writes the zip to a temp file first, so a partly written zip is never served
+func (s *takeoutService) writeZip(userID uuid.UUID, path string) error {
+	file, err := os.CreateTemp(s.dir, "takeout-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := s.buildZip(userID, file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// buildZip writes each part of the user's data as an indented json file
+func (s *takeoutService) buildZip(userID uuid.UUID, out io.Writer) error {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", s.takeoutRepo.GetProfile(userID)},
+		{"devices.json", s.deviceRepo.GetMany(userID)},
+		{"suggested_emails.json", s.suggestedEmailsRepo.GetMany(userID)},
+		{"plan_categories.json", s.planCategoryRepo.GetMany(userID)},
+		{"preferences.json", getPreferences(s.preferencesRepo, s.cfg, userID)},
+		{"plans.json", s.takeoutRepo.GetOwnedPlans(userID)},
+		{"memberships.json", s.takeoutRepo.GetMemberships(userID)},
+		{"tasks.json", s.takeoutRepo.GetOwnedTasks(userID)},
+	}
+
+	zw := zip.NewWriter(out)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
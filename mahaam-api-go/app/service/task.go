@@ -13,9 +13,11 @@ import (
 type TaskService interface {
 	Create(planID uuid.UUID, title string) uuid.UUID
 	GetList(planID uuid.UUID) []Task
+	GetPage(planID uuid.UUID, q ListQuery) models.Page[Task]
 	Delete(planID, id uuid.UUID)
 	UpdateDone(planID, id uuid.UUID, done bool)
 	UpdateTitle(id uuid.UUID, title string)
+	UpdateDue(id uuid.UUID, due *string)
 	ReOrder(planID uuid.UUID, oldOrder, newOrder int)
 }
 
@@ -46,6 +48,7 @@ func (s *taskService) Create(planID uuid.UUID, title string) uuid.UUID {
 	err := repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
 		id = s.taskRepo.Create(tx, planID, title)
 		s.planRepo.UpdateDonePercent(tx, planID)
+		s.planRepo.SyncStatusWithTasks(tx, planID)
 		return nil
 	})
 	if err != nil {
@@ -58,11 +61,16 @@ func (s *taskService) GetList(planID uuid.UUID) []Task {
 	return s.taskRepo.GetAll(planID)
 }
 
+func (s *taskService) GetPage(planID uuid.UUID, q ListQuery) models.Page[Task] {
+	return s.taskRepo.GetPage(planID, q)
+}
+
 func (s *taskService) Delete(planID, id uuid.UUID) {
 	txFunc := func(tx *sqlx.Tx) error {
 		s.taskRepo.UpdateOrderBeforeDelete(tx, planID, id)
 		s.taskRepo.DeleteOne(tx, id)
 		s.planRepo.UpdateDonePercent(tx, planID)
+		s.planRepo.SyncStatusWithTasks(tx, planID)
 		return nil
 	}
 
@@ -75,6 +83,7 @@ func (s *taskService) UpdateDone(planID, id uuid.UUID, done bool) {
 	txFunc := func(tx *sqlx.Tx) error {
 		s.taskRepo.UpdateDone(tx, id, done)
 		s.planRepo.UpdateDonePercent(tx, planID)
+		s.planRepo.SyncStatusWithTasks(tx, planID)
 		tasks := s.taskRepo.GetAll(planID)
 		taskIndex := slices.IndexFunc(tasks, func(t Task) bool {
 			return t.ID == id
@@ -93,6 +102,10 @@ func (s *taskService) UpdateTitle(id uuid.UUID, title string) {
 	s.taskRepo.UpdateTitle(id, title)
 }
 
+func (s *taskService) UpdateDue(id uuid.UUID, due *string) {
+	s.taskRepo.UpdateDue(id, due)
+}
+
 func (s *taskService) ReOrder(planID uuid.UUID, oldOrder, newOrder int) {
 	txFunc := func(tx *sqlx.Tx) error {
 		s.reOrderWithTx(planID, oldOrder, newOrder, tx)
@@ -8,8 +8,21 @@ type SuggestedEmail = models.SuggestedEmail
 type Device = models.Device
 type Plan = models.Plan
 type PlanIn = models.PlanIn
+type PlanCategory = models.PlanCategory
 type Task = models.Task
 type User = models.User
 type CreatedUser = models.CreatedUser
 type VerifiedUser = models.VerifiedUser
 type Meta = models.Meta
+type SearchResult = models.SearchResult
+type ListQuery = models.ListQuery
+type CalendarFeed = models.CalendarFeed
+type TakeoutJob = models.TakeoutJob
+type AuthAttempt = models.AuthAttempt
+type RateLimitHit = models.RateLimitHit
+type AccessToken = models.AccessToken
+type OidcAuthorization = models.OidcAuthorization
+type Preferences = models.Preferences
+type PreferencesIn = models.PreferencesIn
+type ContactGroup = models.ContactGroup
+type BlockedUser = models.BlockedUser
@@ -1,37 +1,64 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mahaam-api/app/models"
 	"mahaam-api/app/repo"
+	"mahaam-api/utils/attest"
 	"mahaam-api/utils/conf"
 	emails "mahaam-api/utils/email"
 	logs "mahaam-api/utils/log"
+	"mahaam-api/utils/oidc"
 	token "mahaam-api/utils/token"
+	"net/mail"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type UserService interface {
-	Create(device Device) *CreatedUser
-	SendMeOtp(email string) string
+	Create(device Device, attestation attest.Request) *CreatedUser
+	SendMeOtp(meta Meta, email string) string
 	VerifyOtp(meta Meta, email, sid, otp string) *VerifiedUser
+	StartOidc(meta Meta, provider string) *OidcAuthorization
+	VerifyOidc(meta Meta, state, code string) *VerifiedUser
 	RefreshToken(meta Meta) *VerifiedUser
+	RotateRefreshToken(refreshToken string) *VerifiedUser
 	UpdateName(userID uuid.UUID, name string) int64
+	StartEmailChange(meta Meta, newEmail string) *models.EmailChange
+	ChangeEmail(meta Meta, newEmail, oldSid, oldOtp, newSid, newOtp string) string
 	Logout(userID uuid.UUID, deviceId uuid.UUID) int64
-	Delete(userID uuid.UUID, sid, otp string)
-	GetDevices(userID uuid.UUID) []Device
+	LogoutOthers(meta Meta) int64
+	RenameDevice(userID, deviceID uuid.UUID, name string)
+	Delete(meta Meta, sid, otp string) *models.AccountDeletion
+	GetDevices(meta Meta) []Device
 	GetSuggestedEmails(userID uuid.UUID) []SuggestedEmail
 	DeleteSuggestedEmail(userID uuid.UUID, suggestedEmailId uuid.UUID)
+	StartAccountDeletion(ctx context.Context)
 }
 
 type userService struct {
 	userRepo            repo.UserRepo
 	deviceRepo          repo.DeviceRepo
 	planRepo            repo.PlanRepo
+	planCategoryRepo    repo.PlanCategoryRepo
+	planPinsRepo        repo.PlanPinsRepo
 	suggestedEmailsRepo repo.SuggestedEmailRepo
+	accessTokenRepo     repo.AccessTokenRepo
+	oidcRepo            repo.OidcRepo
+	oidcProviders       map[string]oidc.Provider
+	attestVerifier      attest.Verifier
 	tokenService        token.TokenService
 	emailService        emails.EmailService
+	notifier            emails.Notifier
+	otpVerifyGuard      attemptGuard
+	otpSendLimit        rateLimit
 	db                  *repo.AppDB
 	cfg                 *conf.Conf
 	logger              logs.Logger
@@ -41,37 +68,77 @@ func NewUserService(db *repo.AppDB,
 	userRepo repo.UserRepo,
 	deviceRepo repo.DeviceRepo,
 	planRepo repo.PlanRepo,
+	planCategoryRepo repo.PlanCategoryRepo,
+	planPinsRepo repo.PlanPinsRepo,
 	suggestedEmailsRepo repo.SuggestedEmailRepo,
+	authAttemptRepo repo.AuthAttemptRepo,
+	rateLimitRepo repo.RateLimitRepo,
+	accessTokenRepo repo.AccessTokenRepo,
+	oidcRepo repo.OidcRepo,
+	oidcProviders map[string]oidc.Provider,
+	attestVerifier attest.Verifier,
 	tokenService token.TokenService,
 	emailService emails.EmailService,
+	notifier emails.Notifier,
 	cfg *conf.Conf,
 	logger logs.Logger,
 ) UserService {
+	switch cfg.SharedPlansOnDeletion {
+	case "", sharedPlansTransfer, sharedPlansDelete:
+	default:
+		log.Fatal("Error loading sharedPlansOnDeletion: unknown value " + cfg.SharedPlansOnDeletion)
+	}
 	return &userService{
 		userRepo:            userRepo,
 		deviceRepo:          deviceRepo,
 		planRepo:            planRepo,
+		planCategoryRepo:    planCategoryRepo,
+		planPinsRepo:        planPinsRepo,
 		suggestedEmailsRepo: suggestedEmailsRepo,
+		accessTokenRepo:     accessTokenRepo,
+		oidcRepo:            oidcRepo,
+		oidcProviders:       oidcProviders,
+		attestVerifier:      attestVerifier,
 		tokenService:        tokenService,
 		emailService:        emailService,
+		notifier:            notifier,
+		otpVerifyGuard:      newOtpVerifyGuard(authAttemptRepo),
+		otpSendLimit:        newOtpSendRateLimit(rateLimitRepo),
 		db:                  db,
 		cfg:                 cfg,
 		logger:              logger,
 	}
 }
 
-func (s *userService) Create(device Device) *CreatedUser {
-	var jwt string
+// Create verifies the device attestation before creating the user, so emulators cannot mass create accounts
+func (s *userService) Create(device Device, attestation attest.Request) *CreatedUser {
+	attestation.Platform = device.Platform
+	attestation.Nonce = device.Fingerprint
+	result, err := s.attestVerifier.Verify(attestation)
+	if err == attest.ErrMissingToken {
+		panic(models.InputError(err.Error()))
+	}
+	if err != nil {
+		panic(models.ServerError("attestation failed: " + err.Error()))
+	}
+	if !result.Passed() {
+		e := models.ForbiddenError("Device should be real, not a simulator")
+		e.Key = "attestation_failed"
+		panic(e)
+	}
+	device.AttestationProvider = &result.Provider
+	device.AttestationVerdict = &result.Verdict
+
+	var jwt, refreshToken string
 	var userId uuid.UUID
 	var deviceId uuid.UUID
-	var err error
 
 	txFn := func(tx *sqlx.Tx) error {
 		userId = s.userRepo.Create(tx)
 		s.deviceRepo.DeleteByFingerprint(tx, device.Fingerprint)
 		device.UserID = userId
 		deviceId = s.deviceRepo.Create(tx, device)
-		if jwt, err = s.tokenService.Create(userId, deviceId); err != nil {
+		if jwt, refreshToken, err = s.tokenService.CreateSession(tx, userId, deviceId); err != nil {
 			return err
 		}
 		return nil
@@ -81,14 +148,19 @@ func (s *userService) Create(device Device) *CreatedUser {
 		panic(err)
 	}
 
-	return &CreatedUser{ID: userId, DeviceID: deviceId, Jwt: jwt}
+	return &CreatedUser{ID: userId, DeviceID: deviceId, Jwt: jwt, RefreshToken: refreshToken}
 }
 
-func (s *userService) SendMeOtp(email string) string {
+// SendMeOtp is limited per email and per device in a window
+func (s *userService) SendMeOtp(meta Meta, email string) string {
 	var verifySid string
 	if slices.Contains(s.cfg.TestEmails, email) {
 		verifySid = s.cfg.TestSID
 	} else {
+		if len(email) > 255 {
+			panic(models.InputError("email is not valid"))
+		}
+		s.otpSendLimit.take(otpSendKey(email), otpSendDeviceKey(meta.DeviceID))
 		var err error
 		verifySid, err = s.emailService.SendOtp(email)
 		if err != nil {
@@ -98,50 +170,103 @@ func (s *userService) SendMeOtp(email string) string {
 	return verifySid
 }
 
-func (s *userService) VerifyOtp(meta Meta, email, sid, otp string) *VerifiedUser {
+// verifyOtp panics unless the otp is approved, and uses it up
+func (s *userService) verifyOtp(meta Meta, email, sid, otp string) {
+	s.checkOtp(meta, email, sid, otp)
+	s.useOtp(email, sid)
+}
+
+// checkOtp panics unless the otp is approved, without using it up. Attempts are counted per email and per device
+// before checking, and lock both with a delay doubling on each attempt after the free ones, until one succeeds.
+// Attempts of a single otp are limited by the otp provider.
+func (s *userService) checkOtp(meta Meta, email, sid, otp string) {
+	if len(email) > 255 || len(sid) > 64 {
+		panic(models.InputError("email or sid is not valid"))
+	}
+	keys := []string{otpVerifyKey(email), otpVerifyDeviceKey(meta.DeviceID)}
+	wait := s.otpVerifyGuard.take(keys...)
+
 	var otpStatus string
 	var err error
-
 	if slices.Contains(s.cfg.TestEmails, email) && sid == s.cfg.TestSID && otp == s.cfg.TestOTP {
-		otpStatus = "approved"
+		otpStatus = emails.OtpStatusApproved
 	} else {
-		otpStatus, err = s.emailService.VerifyOtp(otp, sid, email)
+		otpStatus, err = s.emailService.CheckOtp(otp, sid, email)
 	}
 
-	if otpStatus != "approved" {
-		panic("OTP not verified for " + email + ", status: " + otpStatus)
+	if err != nil || otpStatus != emails.OtpStatusApproved {
+		s.logger.Info(uuid.Nil, "OTP not verified for %s, status: %s", email, otpStatus)
+		if wait > 0 {
+			panic(models.TooManyAttemptsError("otp_verify_locked", wait))
+		}
+		panic(models.LogicError("OTP is not correct or expired", "invalid_otp"))
 	}
+	s.otpVerifyGuard.reset(keys...)
+}
+
+func (s *userService) useOtp(email, sid string) {
+	if slices.Contains(s.cfg.TestEmails, email) && sid == s.cfg.TestSID {
+		return
+	}
+	if err := s.emailService.UseOtp(sid); err != nil {
+		panic(models.LogicError("OTP is not correct or expired", "invalid_otp"))
+	}
+}
+
+func (s *userService) VerifyOtp(meta Meta, email, sid, otp string) *VerifiedUser {
+	s.verifyOtp(meta, email, sid, otp)
+	return s.login(meta, email, s.userRepo.GetOneByEmail(email), nil)
+}
 
-	var jwt string
+// login signs the device in as user, merging the device's anonymous user into it. Without user, the device's user
+// takes the email. It is shared by otp and oidc logins, link runs in the same transaction with the logged in user id.
+func (s *userService) login(meta Meta, email string, user *User, link func(tx *sqlx.Tx, userID uuid.UUID)) *VerifiedUser {
+	var err error
+	var jwt, refreshToken string
 	var newUserId uuid.UUID
-	var user *User
+	deletionCancelled := false
 
 	txFn := func(tx *sqlx.Tx) error {
-		user = s.userRepo.GetOneByEmail(email)
 		if user == nil {
 			s.userRepo.UpdateEmail(tx, meta.UserID, email)
 			newUserId = meta.UserID
 			s.logger.Info(uuid.Nil, "User loggedIn for %s", email)
+		} else if user.ID == meta.UserID {
+			// the device is already logged in as the user, there is nothing to merge
+			newUserId = user.ID
 		} else {
+			if s.userRepo.CancelDeletion(tx, user.ID) == 1 {
+				deletionCancelled = true
+			}
+			s.planCategoryRepo.UpdateUserID(tx, meta.UserID, user.ID)
 			s.planRepo.UpdateUserID(tx, meta.UserID, user.ID)
+			s.planPinsRepo.UpdateUserID(tx, meta.UserID, user.ID)
+			// the least recently used devices make room for this one
 			devices := s.deviceRepo.GetMany(user.ID)
-
-			if len(devices) >= 5 {
-				s.deviceRepo.Delete(tx, devices[len(devices)-1].ID)
+			for i := len(devices) - 1; i >= 0 && i >= s.devicesLimit()-1; i-- {
+				s.deviceRepo.Delete(tx, devices[i].ID)
 			}
 			s.deviceRepo.UpdateUserID(tx, meta.DeviceID, user.ID)
 			s.userRepo.Delete(tx, meta.UserID)
 			newUserId = user.ID
 			s.logger.Info(uuid.Nil, "Merging userId:%s to %s", meta.UserID, user.ID)
 		}
+		if link != nil {
+			link(tx, newUserId)
+		}
 
-		jwt, err = s.tokenService.Create(newUserId, meta.DeviceID)
+		jwt, refreshToken, err = s.tokenService.CreateSession(tx, newUserId, meta.DeviceID)
 		return err
 	}
 
 	if err = repo.WithTransaction(s.db, txFn); err != nil {
 		panic(err)
 	}
+	// notified once committed, so a rolled back login does not claim the deletion was cancelled
+	if deletionCancelled {
+		s.logger.Info(uuid.Nil, "Account deletion of %s cancelled by login", user.ID)
+		s.notifier.Notify(email, emails.TemplateAccountDeletionCancelled, "", nil)
+	}
 
 	userFullName := ""
 	if user != nil && user.Name != nil {
@@ -151,21 +276,56 @@ func (s *userService) VerifyOtp(meta Meta, email, sid, otp string) *VerifiedUser
 		UserID:       newUserId,
 		DeviceID:     meta.DeviceID,
 		Jwt:          jwt,
+		RefreshToken: refreshToken,
 		UserFullName: &userFullName,
 		Email:        &email,
 	}
 }
 
+// RefreshToken moves a device logged in before refresh tokens to a session, once. Its access token has no family,
+// which is accepted only while the device has no session, afterwards it renews with RotateRefreshToken.
 func (s *userService) RefreshToken(meta Meta) *VerifiedUser {
-	user := s.userRepo.GetOne(meta.UserID)
-	jwt, err := s.tokenService.Create(meta.UserID, meta.DeviceID)
-	if err != nil {
-		return nil
+	if s.tokenService.HasSession(meta.DeviceID) {
+		panic(models.ForbiddenError("device has a session, use its refresh token"))
+	}
+	var jwt, refreshToken string
+	txFn := func(tx *sqlx.Tx) error {
+		var err error
+		jwt, refreshToken, err = s.tokenService.CreateSession(tx, meta.UserID, meta.DeviceID)
+		return err
 	}
+	if err := repo.WithTransaction(s.db, txFn); err != nil {
+		panic(err)
+	}
+	user := s.userRepo.GetOne(meta.UserID)
 	return &VerifiedUser{
 		UserID:       meta.UserID,
 		DeviceID:     meta.DeviceID,
 		Jwt:          jwt,
+		RefreshToken: refreshToken,
+		UserFullName: user.Name,
+		Email:        user.Email,
+	}
+}
+
+// RotateRefreshToken exchanges a refresh token for new access and refresh tokens, without the access token
+func (s *userService) RotateRefreshToken(refreshToken string) *VerifiedUser {
+	userID, deviceID, jwt, next, err := s.tokenService.Refresh(refreshToken)
+	if errors.Is(err, token.ErrRefreshTokenReused) {
+		s.logger.Error(uuid.Nil, "Refresh token reused, its session is revoked")
+		reused := models.UnauthorizedError("refresh token reused, log in again")
+		reused.Key = "refresh_token_reused"
+		panic(reused)
+	}
+	if err != nil {
+		panic(models.UnauthorizedError(err.Error()))
+	}
+	user := s.userRepo.GetOne(userID)
+	return &VerifiedUser{
+		UserID:       userID,
+		DeviceID:     deviceID,
+		Jwt:          jwt,
+		RefreshToken: next,
 		UserFullName: user.Name,
 		Email:        user.Email,
 	}
@@ -175,6 +335,61 @@ func (s *userService) UpdateName(userID uuid.UUID, name string) int64 {
 	return s.userRepo.UpdateName(userID, name)
 }
 
+// StartEmailChange sends otps to the current and the new email, the new email must not belong to another user
+func (s *userService) StartEmailChange(meta Meta, newEmail string) *models.EmailChange {
+	user := s.emailChangeUser(meta.UserID, newEmail)
+	return &models.EmailChange{
+		OldEmailSid: s.SendMeOtp(meta, *user.Email),
+		NewEmailSid: s.SendMeOtp(meta, newEmail),
+	}
+}
+
+// ChangeEmail changes the email once the otps of both emails are verified, and moves suggestions of the old email to it.
+// Both otps are checked before either is used, so a wrong otp of one email does not use up the other.
+// Other devices are logged out, as the account may have been taken over.
+func (s *userService) ChangeEmail(meta Meta, newEmail, oldSid, oldOtp, newSid, newOtp string) string {
+	userID := meta.UserID
+	user := s.emailChangeUser(userID, newEmail)
+	oldEmail := *user.Email
+	s.checkOtp(meta, oldEmail, oldSid, oldOtp)
+	s.checkOtp(meta, newEmail, newSid, newOtp)
+	s.useOtp(oldEmail, oldSid)
+	s.useOtp(newEmail, newSid)
+
+	err := repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		if !s.userRepo.ChangeEmail(tx, userID, newEmail) {
+			return models.LogicError("email is used by another account", "email_taken")
+		}
+		s.suggestedEmailsRepo.ReplaceEmail(tx, oldEmail, newEmail)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	loggedOut := s.deviceRepo.DeleteByUser(userID, meta.DeviceID)
+	s.logger.Info(uuid.Nil, "User %s changed email from %s to %s, logged out of %d other devices", userID, oldEmail, newEmail, loggedOut)
+	s.notifier.Notify(oldEmail, emails.TemplateEmailChanged, "", map[string]any{"newEmail": newEmail})
+	return newEmail
+}
+
+func (s *userService) emailChangeUser(userID uuid.UUID, newEmail string) *User {
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail || len(newEmail) > 255 {
+		panic(models.InputError("email is not valid"))
+	}
+	user := s.userRepo.GetOne(userID)
+	if user == nil || user.Email == nil {
+		panic(models.LogicError("log in with your email to change it", "email_required"))
+	}
+	if strings.EqualFold(*user.Email, newEmail) {
+		panic(models.InputError("new email is the same as the current one"))
+	}
+	if s.userRepo.GetOneByEmail(newEmail) != nil {
+		panic(models.LogicError("email is used by another account", "email_taken"))
+	}
+	return user
+}
+
 func (s *userService) Logout(userID uuid.UUID, deviceId uuid.UUID) int64 {
 
 	device := s.deviceRepo.GetOne(deviceId)
@@ -190,33 +405,144 @@ func (s *userService) Logout(userID uuid.UUID, deviceId uuid.UUID) int64 {
 	return int64(rows)
 }
 
-func (s *userService) Delete(userID uuid.UUID, sid, otp string) {
+// LogoutOthers logs the user out of all devices except the current one, their refresh tokens go with them
+func (s *userService) LogoutOthers(meta Meta) int64 {
+	rows := s.deviceRepo.DeleteByUser(meta.UserID, meta.DeviceID)
+	s.logger.Info(uuid.Nil, "User %s logged out of %d other devices", meta.UserID, rows)
+	return rows
+}
+
+func (s *userService) RenameDevice(userID, deviceID uuid.UUID, name string) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > deviceNameMaxSize {
+		panic(models.InputError(fmt.Sprintf("name should be between 1 and %d characters", deviceNameMaxSize)))
+	}
+	if s.deviceRepo.UpdateName(userID, deviceID, name) == 0 {
+		panic(models.NotFoundError("device not found"))
+	}
+}
+
+func (s *userService) Delete(meta Meta, sid, otp string) *models.AccountDeletion {
+	userID := meta.UserID
 	user := s.userRepo.GetOne(userID)
-	var otpStatus string
-	var err error
-	if slices.Contains(s.cfg.TestEmails, *user.Email) && sid == s.cfg.TestSID && otp == s.cfg.TestOTP {
-		otpStatus = "approved"
-	} else {
-		otpStatus, err = s.emailService.VerifyOtp(otp, sid, *user.Email)
-		if err != nil {
-			panic("OTP not verified for " + *user.Email + ", status: " + otpStatus)
-		}
+	if user.Email == nil {
+		panic(models.LogicError("log in with your email to delete the account", "email_required"))
 	}
+	s.verifyOtp(meta, *user.Email, sid, otp)
 
-	if otpStatus != "approved" {
-		panic("OTP not verified for " + *user.Email + ", status: " + otpStatus)
+	if s.cfg.AccountDeletionGraceDays <= 0 {
+		s.deleteAccount(user, false)
+		return nil
 	}
 
-	repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
-		s.suggestedEmailsRepo.DeleteManyByEmail(*user.Email)
-		s.userRepo.Delete(tx, userID)
+	// the user is logged out of all devices and scripts, logging in again before deletesAt cancels the deletion
+	deletesAt := time.Now().AddDate(0, 0, s.cfg.AccountDeletionGraceDays)
+	s.userRepo.ScheduleDeletion(userID, deletesAt)
+	s.deviceRepo.DeleteByUser(userID, uuid.Nil)
+	s.accessTokenRepo.DeleteByUser(userID)
+	s.logger.Info(uuid.Nil, "Account deletion of %s scheduled at %s", userID, deletesAt.Format(time.RFC3339))
+	s.notifier.Notify(*user.Email, emails.TemplateAccountDeletionScheduled, "", map[string]any{"deletesAt": deletesAt.Format(time.DateOnly)})
+	return &models.AccountDeletion{DeletesAt: deletesAt}
+}
+
+const accountDeletionBatchSize = 100
+
+const (
+	defaultDevicesLimit = 5
+	deviceNameMaxSize   = 100
+)
+
+// what happens to the shared plans of a deleted user, transfer when not set
+const (
+	sharedPlansTransfer = "transfer"
+	sharedPlansDelete   = "delete"
+)
+
+func (s *userService) devicesLimit() int {
+	if s.cfg.DevicesLimit > 0 {
+		return s.cfg.DevicesLimit
+	}
+	return defaultDevicesLimit
+}
+
+// deleteAccount deletes the user with its plans, shared plans are given to their oldest member unless configured otherwise.
+// When due is true, the user is deleted only if the deletion was not cancelled meanwhile.
+func (s *userService) deleteAccount(user *User, due bool) bool {
+	deleted := false
+	err := repo.WithTransaction(s.db, func(tx *sqlx.Tx) error {
+		if s.cfg.SharedPlansOnDeletion != sharedPlansDelete {
+			s.planRepo.TransferSharedPlans(tx, user.ID)
+		}
+		if user.Email != nil {
+			s.suggestedEmailsRepo.DeleteManyByEmail(tx, *user.Email)
+		}
+		if due {
+			if s.userRepo.DeleteDue(tx, user.ID) == 0 {
+				return errors.New("account deletion cancelled")
+			}
+		} else {
+			s.userRepo.Delete(tx, user.ID)
+		}
+		deleted = true
 		return nil
 	})
+	return err == nil && deleted
+}
+
+func (s *userService) StartAccountDeletion(ctx context.Context) {
+	if s.cfg.AccountDeletionGraceDays <= 0 {
+		return
+	}
+	go s.startAccountDeletion(ctx)
+}
+
+func (s *userService) startAccountDeletion(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runAccountDeletion()
+		}
+	}
+}
 
+// runAccountDeletion deletes accounts whose grace period ended, in batches
+func (s *userService) runAccountDeletion() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(uuid.Nil, "Account deletion failed: %v", r)
+		}
+	}()
+	deletedCount := 0
+	for {
+		users := s.userRepo.GetDueForDeletion(accountDeletionBatchSize)
+		batchCount := 0
+		for i := range users {
+			if s.deleteAccount(&users[i], true) {
+				batchCount++
+			}
+		}
+		deletedCount += batchCount
+		if len(users) < accountDeletionBatchSize || batchCount == 0 {
+			break
+		}
+	}
+	if deletedCount > 0 {
+		s.logger.Info(uuid.Nil, "Deleted %d accounts after their grace period", deletedCount)
+	}
 }
 
-func (s *userService) GetDevices(userID uuid.UUID) []Device {
-	return s.deviceRepo.GetMany(userID)
+// GetDevices returns the user's devices, most recently used first
+func (s *userService) GetDevices(meta Meta) []Device {
+	devices := s.deviceRepo.GetMany(meta.UserID)
+	for i := range devices {
+		devices[i].Current = devices[i].ID == meta.DeviceID
+	}
+	return devices
 }
 
 func (s *userService) GetSuggestedEmails(userID uuid.UUID) []SuggestedEmail {
@@ -15,10 +15,12 @@ import (
 	"mahaam-api/app/models"
 	"mahaam-api/app/repo"
 	"mahaam-api/app/service"
+	"mahaam-api/utils/attest"
 	"mahaam-api/utils/conf"
 	emails "mahaam-api/utils/email"
 	logs "mahaam-api/utils/log"
 	"mahaam-api/utils/middleware"
+	"mahaam-api/utils/oidc"
 	token "mahaam-api/utils/token"
 
 	"github.com/gin-gonic/gin"
@@ -30,9 +32,23 @@ import (
 type repos struct {
 	plan            repo.PlanRepo
 	planMembers     repo.PlanMembersRepo
+	planCategory    repo.PlanCategoryRepo
+	planPins        repo.PlanPinsRepo
 	user            repo.UserRepo
 	suggestedEmails repo.SuggestedEmailRepo
+	contactGroup    repo.ContactGroupRepo
+	blockedUser     repo.BlockedUserRepo
 	task            repo.TaskRepo
+	calendarFeed    repo.CalendarFeedRepo
+	otp             repo.OtpRepo
+	authAttempt     repo.AuthAttemptRepo
+	rateLimit       repo.RateLimitRepo
+	refreshToken    repo.RefreshTokenRepo
+	accessToken     repo.AccessTokenRepo
+	oidc            repo.OidcRepo
+	preferences     repo.PreferencesRepo
+	cleanup         repo.CleanupRepo
+	takeout         repo.TakeoutRepo
 	device          repo.DeviceRepo
 	log             repo.LogRepo
 	traffic         repo.TrafficRepo
@@ -40,18 +56,38 @@ type repos struct {
 }
 
 type services struct {
-	health service.HealthService
-	plan   service.PlanService
-	task   service.TaskService
-	user   service.UserService
+	health       service.HealthService
+	plan         service.PlanService
+	planCategory service.PlanCategoryService
+	task         service.TaskService
+	user         service.UserService
+	contact      service.ContactService
+	search       service.SearchService
+	export       service.ExportService
+	imports      service.ImportService
+	calendarFeed service.CalendarFeedService
+	takeout      service.TakeoutService
+	accessToken  service.AccessTokenService
+	preferences  service.PreferencesService
+	cleanup      service.CleanupService
 }
 
 type handlers struct {
-	user   handler.UserHandler
-	plan   handler.PlanHandler
-	audit  handler.AuditHandler
-	health handler.HealthHandler
-	task   handler.TaskHandler
+	user         handler.UserHandler
+	contact      handler.ContactHandler
+	plan         handler.PlanHandler
+	planCategory handler.PlanCategoryHandler
+	audit        handler.AuditHandler
+	health       handler.HealthHandler
+	task         handler.TaskHandler
+	search       handler.SearchHandler
+	export       handler.ExportHandler
+	imports      handler.ImportHandler
+	calendarFeed handler.CalendarFeedHandler
+	takeout      handler.TakeoutHandler
+	accessToken  handler.AccessTokenHandler
+	preferences  handler.PreferencesHandler
+	jwks         handler.JwksHandler
 }
 
 func loadConfig() *conf.Conf {
@@ -71,9 +107,23 @@ func initRepos(db *repo.AppDB) repos {
 	return repos{
 		plan:            repo.NewPlanRepo(db),
 		planMembers:     repo.NewPlanMembersRepo(db),
+		planCategory:    repo.NewPlanCategoryRepo(db),
+		planPins:        repo.NewPlanPinsRepo(db),
 		user:            repo.NewUserRepo(db),
 		suggestedEmails: repo.NewSuggestedEmailRepo(db),
+		contactGroup:    repo.NewContactGroupRepo(db),
+		blockedUser:     repo.NewBlockedUserRepo(db),
 		task:            repo.NewTaskRepo(db),
+		calendarFeed:    repo.NewCalendarFeedRepo(db),
+		otp:             repo.NewOtpRepo(db),
+		authAttempt:     repo.NewAuthAttemptRepo(db),
+		rateLimit:       repo.NewRateLimitRepo(db),
+		refreshToken:    repo.NewRefreshTokenRepo(db),
+		accessToken:     repo.NewAccessTokenRepo(db),
+		oidc:            repo.NewOidcRepo(db),
+		preferences:     repo.NewPreferencesRepo(db),
+		cleanup:         repo.NewCleanupRepo(db),
+		takeout:         repo.NewTakeoutRepo(db),
 		device:          repo.NewDeviceRepo(db),
 		log:             repo.NewLogRepo(db),
 		traffic:         repo.NewTrafficRepo(db),
@@ -81,29 +131,52 @@ func initRepos(db *repo.AppDB) repos {
 	}
 }
 
-func initUtilities(cfg *conf.Conf, logRepo repo.LogRepo, deviceRepo repo.DeviceRepo, userRepo repo.UserRepo) (logs.Logger, token.TokenService, emails.EmailService) {
+func initUtilities(cfg *conf.Conf, logRepo repo.LogRepo, deviceRepo repo.DeviceRepo, userRepo repo.UserRepo, refreshTokenRepo repo.RefreshTokenRepo, accessTokenRepo repo.AccessTokenRepo, otpRepo repo.OtpRepo, preferencesRepo repo.PreferencesRepo) (logs.Logger, token.TokenService, emails.EmailService, emails.Notifier) {
 	logger := logs.NewLogger(cfg, logRepo.Create)
-	tokenService := token.NewTokenService(deviceRepo, userRepo, cfg)
-	emailService := emails.NewEmailService(cfg, logger)
-	return logger, tokenService, emailService
+	tokenService := token.NewTokenService(deviceRepo, userRepo, refreshTokenRepo, accessTokenRepo, cfg)
+	mailer := emails.NewMailer(cfg, logger)
+	emailService := emails.NewEmailService(cfg, logger, otpRepo, mailer)
+	notifier := emails.NewNotifier(mailer, preferencesRepo, cfg, logger)
+	return logger, tokenService, emailService, notifier
 }
 
-func initServices(cfg *conf.Conf, logger logs.Logger, db *repo.AppDB, r repos, tokenService token.TokenService, emailService emails.EmailService) services {
+func initServices(cfg *conf.Conf, logger logs.Logger, db *repo.AppDB, r repos, tokenService token.TokenService, emailService emails.EmailService, notifier emails.Notifier) services {
+	exportService := service.NewExportService(r.plan, r.planMembers, r.task)
 	return services{
-		health: service.NewHealthService(r.health, cfg, logger),
-		plan:   service.NewPlanService(db, r.plan, r.planMembers, r.user, r.suggestedEmails),
-		task:   service.NewTaskService(db, r.task, r.plan),
-		user:   service.NewUserService(db, r.user, r.device, r.plan, r.suggestedEmails, tokenService, emailService, cfg, logger),
+		health:       service.NewHealthService(r.health, cfg, logger),
+		plan:         service.NewPlanService(db, r.plan, r.planMembers, r.planCategory, r.planPins, r.user, r.suggestedEmails, r.contactGroup, r.blockedUser, r.preferences, r.rateLimit, notifier, cfg, logger),
+		planCategory: service.NewPlanCategoryService(db, r.planCategory, r.plan, r.preferences),
+		task:         service.NewTaskService(db, r.task, r.plan),
+		user:         service.NewUserService(db, r.user, r.device, r.plan, r.planCategory, r.planPins, r.suggestedEmails, r.authAttempt, r.rateLimit, r.accessToken, r.oidc, oidc.NewProviders(cfg), attest.NewVerifier(cfg), tokenService, emailService, notifier, cfg, logger),
+		contact:      service.NewContactService(r.suggestedEmails, r.contactGroup, r.blockedUser, r.user),
+		search:       service.NewSearchService(r.plan, r.task),
+		export:       exportService,
+		imports:      service.NewImportService(db, r.plan, r.planCategory, r.task),
+		calendarFeed: service.NewCalendarFeedService(r.calendarFeed, exportService),
+		accessToken:  service.NewAccessTokenService(r.accessToken),
+		preferences:  service.NewPreferencesService(r.preferences, r.planCategory, cfg),
+		cleanup:      service.NewCleanupService(r.cleanup, cfg, logger),
+		takeout:      service.NewTakeoutService(r.takeout, r.device, r.suggestedEmails, r.planCategory, r.preferences, notifier, cfg, logger),
 	}
 }
 
-func initHandlers(svcs services, logger logs.Logger, cfg *conf.Conf) handlers {
+func initHandlers(svcs services, logger logs.Logger, cfg *conf.Conf, tokenService token.TokenService) handlers {
 	return handlers{
-		user:   handler.NewUserHandler(svcs.user, logger),
-		plan:   handler.NewPlanHandler(svcs.plan, logger),
-		audit:  handler.NewAuditHandler(logger),
-		health: handler.NewHealthHandler(cfg),
-		task:   handler.NewTaskHandler(svcs.task),
+		user:         handler.NewUserHandler(svcs.user, logger),
+		contact:      handler.NewContactHandler(svcs.contact),
+		plan:         handler.NewPlanHandler(svcs.plan, logger),
+		planCategory: handler.NewPlanCategoryHandler(svcs.planCategory),
+		audit:        handler.NewAuditHandler(logger),
+		health:       handler.NewHealthHandler(cfg),
+		task:         handler.NewTaskHandler(svcs.task),
+		search:       handler.NewSearchHandler(svcs.search),
+		export:       handler.NewExportHandler(svcs.export),
+		imports:      handler.NewImportHandler(svcs.imports),
+		calendarFeed: handler.NewCalendarFeedHandler(svcs.calendarFeed),
+		takeout:      handler.NewTakeoutHandler(svcs.takeout),
+		accessToken:  handler.NewAccessTokenHandler(svcs.accessToken),
+		preferences:  handler.NewPreferencesHandler(svcs.preferences),
+		jwks:         handler.NewJwksHandler(tokenService),
 	}
 }
 
@@ -111,15 +184,25 @@ func buildRouter(cfg *conf.Conf, r repos, logger logs.Logger, tokenService token
 	router := gin.Default()
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	handler.RegisterJwksHandler(&router.RouterGroup, h.jwks)
 	authed := router.Group("/mahaam-api")
 	authed.Use(middleware.TrafficMiddleware(r.traffic, cfg, logger))
 	authed.Use(middleware.RecoveryMiddleware(logger))
-	authed.Use(middleware.AuthMiddleware(&tokenService, logger))
+	authed.Use(middleware.AuthMiddleware(cfg, &tokenService, r.device, logger))
 
 	// Register routes
 	handler.RegisterUserHandler(authed, h.user)
+	handler.RegisterContactHandler(authed, h.contact)
 	handler.RegisterPlanHandler(authed, h.plan)
+	handler.RegisterPlanCategoryHandler(authed, h.planCategory)
 	handler.RegisterTaskHandler(authed, h.task)
+	handler.RegisterSearchHandler(authed, h.search)
+	handler.RegisterExportHandler(authed, h.export)
+	handler.RegisterImportHandler(authed, h.imports)
+	handler.RegisterCalendarFeedHandler(authed, h.calendarFeed)
+	handler.RegisterTakeoutHandler(authed, h.takeout)
+	handler.RegisterAccessTokenHandler(authed, h.accessToken)
+	handler.RegisterPreferencesHandler(authed, h.preferences)
 	handler.RegisterAuditHandler(authed, h.audit)
 	handler.RegisterHealthHandler(authed, h.health)
 
@@ -183,6 +266,15 @@ func startPulse(healthSvc service.HealthService) (context.Context, context.Cance
 	return pulseCtx, pulseCancel
 }
 
+func startJobs(svcs services) (context.Context, context.CancelFunc) {
+	jobsCtx, jobsCancel := context.WithCancel(context.Background())
+	svcs.plan.StartAutoArchiving(jobsCtx)
+	svcs.takeout.StartTakeoutJobs(jobsCtx)
+	svcs.user.StartAccountDeletion(jobsCtx)
+	svcs.cleanup.StartAnonymousCleanup(jobsCtx)
+	return jobsCtx, jobsCancel
+}
+
 func gracefulShutdown(srv *http.Server, healthSvc service.HealthService, logger logs.Logger) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -203,9 +295,9 @@ func main() {
 	defer db.Close()
 
 	r := initRepos(db)
-	logger, tokenService, emailService := initUtilities(cfg, r.log, r.device, r.user)
-	svcs := initServices(cfg, logger, db, r, tokenService, emailService)
-	h := initHandlers(svcs, logger, cfg)
+	logger, tokenService, emailService, notifier := initUtilities(cfg, r.log, r.device, r.user, r.refreshToken, r.accessToken, r.otp, r.preferences)
+	svcs := initServices(cfg, logger, db, r, tokenService, emailService, notifier)
+	h := initHandlers(svcs, logger, cfg, tokenService)
 
 	router := buildRouter(cfg, r, logger, tokenService, h)
 
@@ -214,6 +306,9 @@ func main() {
 	_, pulseCancel := startPulse(svcs.health)
 	defer pulseCancel()
 
+	_, jobsCancel := startJobs(svcs)
+	defer jobsCancel()
+
 	srv := startHTTPServer(router, cfg.HTTPPort)
 
 	gracefulShutdown(srv, svcs.health, logger)
@@ -0,0 +1,90 @@
+package attest
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"mahaam-api/utils/conf"
+)
+
+const (
+	VerdictPhysical = "physical"
+	VerdictEmulator = "emulator"
+	VerdictInvalid  = "invalid"
+)
+
+// ErrMissingToken is returned when the selected provider requires a token and the client sent none
+var ErrMissingToken = errors.New("attestation token is required")
+
+// Request is what the app sends to prove it runs on a real device. Nonce binds the token to
+// the request, the app puts the device fingerprint in the Play Integrity request hash or the
+// App Attest client data.
+type Request struct {
+	Platform        string
+	Token           string
+	Nonce           string
+	ClaimedPhysical bool
+}
+
+// Result is the verdict of a verified request, recorded on the created device
+type Result struct {
+	Provider string
+	Verdict  string
+}
+
+func (r *Result) Passed() bool {
+	return r.Verdict == VerdictPhysical
+}
+
+// Verifier verifies device attestation tokens, like Play Integrity on android or App Attest on ios.
+// It returns an error only when the token could not be checked, a rejected token is a Result.
+type Verifier interface {
+	Verify(req Request) (*Result, error)
+}
+
+// NewVerifier returns the verifier selected by the attestationProvider config, without one the
+// client sent isPhysicalDevice flag is trusted as before
+func NewVerifier(cfg *conf.Conf) Verifier {
+	switch cfg.AttestationProvider {
+	case "":
+		return clientVerifier{}
+	case "stub":
+		if cfg.EnvName != "local" && cfg.EnvName != "dev" {
+			log.Fatal("The stub attestation provider is for local and dev only, envName is " + cfg.EnvName)
+		}
+		return stubVerifier{}
+	}
+	log.Fatal("Unsupported attestation provider: " + cfg.AttestationProvider)
+	return nil
+}
+
+// clientVerifier trusts the app's own claim, it stops nothing but honest simulators
+type clientVerifier struct{}
+
+func (clientVerifier) Verify(req Request) (*Result, error) {
+	result := &Result{Provider: "client", Verdict: VerdictEmulator}
+	if req.ClaimedPhysical {
+		result.Verdict = VerdictPhysical
+	}
+	return result, nil
+}
+
+// stubVerifier is for local and dev envs, tokens look like "stub:<verdict>:<nonce>" so both a
+// passing and a failing device can be simulated, and a token replayed for another nonce is invalid
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(req Request) (*Result, error) {
+	if req.Token == "" {
+		return nil, ErrMissingToken
+	}
+	result := &Result{Provider: "stub", Verdict: VerdictInvalid}
+	parts := strings.SplitN(req.Token, ":", 3)
+	if len(parts) != 3 || parts[0] != "stub" || parts[2] != req.Nonce {
+		return result, nil
+	}
+	if parts[1] == VerdictPhysical || parts[1] == VerdictEmulator {
+		result.Verdict = parts[1]
+	}
+	return result, nil
+}
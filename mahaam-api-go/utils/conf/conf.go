@@ -32,11 +32,65 @@ type Conf struct {
 	LogFileRollingInterval      string
 	HTTPPort                    int
 	TokenSecretKey              string
+	AccessTokenMinutes          int
+	RefreshTokenDays            int
+	DevicesLimit                int
+	JwtKeys                     []JwtKey
 	EmailAccountSID             string
 	EmailVerificationServiceSID string
 	EmailAuthToken              string
+	OtpProvider                 string
+	OtpSender                   string
+	OtpFile                     string
+	OidcProviders               []OidcProvider
+	AttestationProvider         string
+	AppVersions                 []AppVersion
+	SmtpHost                    string
+	SmtpPort                    int
+	SmtpUsername                string
+	SmtpPassword                string
+	SmtpFrom                    string
+	MailerBackend               string
+	MailDefaultLocale           string
 	TestEmails                  []string
 	TestSID                     string
 	TestOTP                     string
 	LogReqEnabled               bool
+	AutoArchiveEnabled          bool
+	AutoArchiveGraceDays        int
+	AccountDeletionGraceDays    int
+	SharedPlansOnDeletion       string
+	AnonymousCleanupEnabled     bool
+	AnonymousCleanupDryRun      bool
+	AnonymousInactiveDays       int
+	TakeoutDir                  string
+}
+
+// JwtKey is a token signing key, one key is "active" and signs new tokens,
+// "verify" keys are still accepted and published, "retired" keys are rejected
+type JwtKey struct {
+	Kid            string
+	Alg            string
+	PrivateKey     string
+	PrivateKeyFile string
+	Status         string
+}
+
+// AppVersion is the version policy of an app store, matched with the x-app-store header.
+// Apps below MinimumVersion are rejected, apps below RecommendedVersion are hinted to upgrade.
+type AppVersion struct {
+	Store              string
+	MinimumVersion     string
+	RecommendedVersion string
+	UpgradeURL         string
+}
+
+// OidcProvider is an OpenID Connect identity provider users can log in with, the redirect url is the app's
+type OidcProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
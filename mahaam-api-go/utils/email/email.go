@@ -1,62 +1,30 @@
 package emails
 
 import (
+	"mahaam-api/app/repo"
 	"mahaam-api/utils/conf"
 	logs "mahaam-api/utils/log"
-
-	"github.com/google/uuid"
-	"github.com/twilio/twilio-go"
-	twilioApi "github.com/twilio/twilio-go/rest/verify/v2"
 )
 
+// EmailService sends otps by email and verifies them. SendOtp returns the sid identifying the otp,
+// CheckOtp returns "approved" when the otp is correct, "pending" when it is not, and UseOtp uses up a checked otp,
+// so several otps can be checked before any of them is used.
 type EmailService interface {
 	SendOtp(email string) (string, error)
-	VerifyOtp(otp, sid, email string) (string, error)
-}
-
-type emailService struct {
-	cfg    *conf.Conf
-	client *twilio.RestClient
-	logger logs.Logger
-}
-
-func NewEmailService(cfg *conf.Conf, logger logs.Logger) EmailService {
-	var client = twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: cfg.EmailAccountSID,
-		Password: cfg.EmailAuthToken,
-	})
-	return &emailService{cfg: cfg, client: client, logger: logger}
-}
-
-func (s *emailService) SendOtp(email string) (string, error) {
-	params := &twilioApi.CreateVerificationParams{}
-	params.SetTo(email)
-	params.SetChannel("email")
-
-	verification, err := s.client.VerifyV2.CreateVerification(s.cfg.EmailVerificationServiceSID, params)
-	if err != nil {
-		s.logger.Error(uuid.Nil, "Error sending OTP to %s: %v", email, err)
-		return "", err
-	}
-	if verification.Sid == nil {
-		return "", nil
-	}
-	return *verification.Sid, nil
+	CheckOtp(otp, sid, email string) (string, error)
+	UseOtp(sid string) error
 }
 
-func (s *emailService) VerifyOtp(otp, sid, email string) (string, error) {
-	params := &twilioApi.CreateVerificationCheckParams{}
-	params.SetTo(email)
-	params.SetCode(otp)
-	params.SetVerificationSid(sid)
+const (
+	OtpStatusApproved = "approved"
+	OtpStatusPending  = "pending"
+	OtpStatusCanceled = "canceled"
+)
 
-	check, err := s.client.VerifyV2.CreateVerificationCheck(s.cfg.EmailVerificationServiceSID, params)
-	if err != nil {
-		s.logger.Info(uuid.Nil, "Error verifying OTP for %s: %v", email, err)
-		return "", err
-	}
-	if check.Status == nil {
-		return "", nil
+// NewEmailService returns the otp provider selected by the otpProvider config, twilio by default
+func NewEmailService(cfg *conf.Conf, logger logs.Logger, otpRepo repo.OtpRepo, mailer Mailer) EmailService {
+	if cfg.OtpProvider == "local" {
+		return newLocalEmailService(cfg, logger, otpRepo, newOtpSender(cfg, logger, mailer))
 	}
-	return *check.Status, nil
+	return newTwilioEmailService(cfg, logger)
 }
@@ -0,0 +1,117 @@
+package emails
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mahaam-api/utils/conf"
+	logs "mahaam-api/utils/log"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is an email with a plain text body and an optional html alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// NewMailer returns the mailer selected by the mailerBackend config: smtp, or log by default
+func NewMailer(cfg *conf.Conf, logger logs.Logger) Mailer {
+	if cfg.MailerBackend == "smtp" {
+		return &smtpMailer{cfg: cfg}
+	}
+	return &logMailer{logger: logger}
+}
+
+// smtpMailer sends with STARTTLS when the server offers it, auth is skipped when no username is set,
+// so a local smtp stand-in like MailHog works with only smtpHost and smtpPort
+type smtpMailer struct {
+	cfg *conf.Conf
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	addr := m.cfg.SmtpHost + ":" + strconv.Itoa(m.cfg.SmtpPort)
+	var auth smtp.Auth
+	if m.cfg.SmtpUsername != "" {
+		auth = smtp.PlainAuth("", m.cfg.SmtpUsername, m.cfg.SmtpPassword, m.cfg.SmtpHost)
+	}
+	body, err := encodeMessage(m.cfg.SmtpFrom, msg)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(addr, auth, m.cfg.SmtpFrom, []string{msg.To}, body)
+}
+
+// encodeMessage builds a multipart/alternative message when there is an html body, plain text otherwise
+func encodeMessage(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		fmt.Fprintf(&buf, "Content-Type: text/plain; charset=utf-8\r\n")
+		if err := writePart(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n", boundary)
+	if err := writePart(&buf, msg.Text); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "\r\n--%s\r\nContent-Type: text/html; charset=utf-8\r\n", boundary)
+	if err := writePart(&buf, msg.HTML); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&buf, "\r\n--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func writePart(buf *bytes.Buffer, content string) error {
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func newBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// logMailer writes emails to the app log instead of sending them, for local development
+type logMailer struct {
+	logger logs.Logger
+}
+
+func (m *logMailer) Send(msg Message) error {
+	m.logger.Info(uuid.Nil, "Email to %s, subject: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
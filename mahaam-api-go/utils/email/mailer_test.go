@@ -0,0 +1,81 @@
+package emails
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		text string
+		html string
+	}{
+		{name: "plain text", msg: Message{To: "a@mahaam.dev", Subject: "Hi", Text: "Your code is 123456"}, text: "Your code is 123456"},
+		{name: "non ascii text", msg: Message{To: "a@mahaam.dev", Subject: "مرحبا", Text: "خطة جديدة"}, text: "خطة جديدة"},
+		{name: "text and html", msg: Message{To: "a@mahaam.dev", Subject: "Hi", Text: "Shared", HTML: "<p>Shared</p>"}, text: "Shared", html: "<p>Shared</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := encodeMessage("noreply@mahaam.dev", tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := mail.ReadMessage(strings.NewReader(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Header.Get("To"); got != tt.msg.To {
+				t.Errorf("To = %q, want %q", got, tt.msg.To)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+			if err != nil || subject != tt.msg.Subject {
+				t.Errorf("Subject = %q, want %q", subject, tt.msg.Subject)
+			}
+
+			parts := map[string]string{}
+			mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mediaType == "multipart/alternative" {
+				r := multipart.NewReader(m.Body, params["boundary"])
+				for {
+					part, err := r.NextRawPart()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+					parts[partType] = readQuotedPrintable(t, part)
+				}
+			} else {
+				parts[mediaType] = readQuotedPrintable(t, m.Body)
+			}
+
+			if parts["text/plain"] != tt.text {
+				t.Errorf("text body = %q, want %q", parts["text/plain"], tt.text)
+			}
+			if parts["text/html"] != tt.html {
+				t.Errorf("html body = %q, want %q", parts["text/html"], tt.html)
+			}
+		})
+	}
+}
+
+func readQuotedPrintable(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
@@ -0,0 +1,67 @@
+package emails
+
+import (
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
+	logs "mahaam-api/utils/log"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TemplateOtp                      = "otp"
+	TemplatePlanShared               = "plan_shared"
+	TemplatePlanInvitation           = "plan_invitation"
+	TemplateAccountDeletionScheduled = "account_deletion_scheduled"
+	TemplateAccountDeletionCancelled = "account_deletion_cancelled"
+	TemplateTakeoutReady             = "takeout_ready"
+	TemplateEmailChanged             = "email_changed"
+)
+
+// Notifier sends templated emails in the background, failures are logged and never fail the request
+type Notifier interface {
+	Notify(to, template, locale string, data map[string]any)
+}
+
+type notifier struct {
+	mailer          Mailer
+	preferencesRepo repo.PreferencesRepo
+	cfg             *conf.Conf
+	logger          logs.Logger
+}
+
+func NewNotifier(mailer Mailer, preferencesRepo repo.PreferencesRepo, cfg *conf.Conf, logger logs.Logger) Notifier {
+	return &notifier{mailer: mailer, preferencesRepo: preferencesRepo, cfg: cfg, logger: logger}
+}
+
+// Notify renders the template in the locale, or in the recipient's preferred locale when locale is empty, and sends it
+// to the email. Users can turn off plan shared and takeout ready emails in their preferences.
+func (n *notifier) Notify(to, template, locale string, data map[string]any) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				n.logger.Error(uuid.Nil, "Sending %s email failed: %v", template, r)
+			}
+		}()
+		if p := n.preferencesRepo.GetOneByEmail(to); p != nil {
+			if (template == TemplatePlanShared && !p.NotifyPlanShared) || (template == TemplateTakeoutReady && !p.NotifyTakeoutReady) {
+				return
+			}
+			if locale == "" {
+				locale = p.Locale
+			}
+		}
+		if locale == "" {
+			locale = n.cfg.MailDefaultLocale
+		}
+		msg, err := Render(template, locale, data)
+		if err != nil {
+			n.logger.Error(uuid.Nil, "Rendering %s email failed: %v", template, err)
+			return
+		}
+		msg.To = to
+		if err := n.mailer.Send(*msg); err != nil {
+			n.logger.Error(uuid.Nil, "Sending %s email to %s failed: %v", template, to, err)
+		}
+	}()
+}
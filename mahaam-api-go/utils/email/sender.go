@@ -0,0 +1,73 @@
+package emails
+
+import (
+	"fmt"
+	"log"
+	"mahaam-api/utils/conf"
+	logs "mahaam-api/utils/log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OtpSender delivers otp codes generated by the local provider
+type OtpSender interface {
+	Send(email, code string) error
+}
+
+// newOtpSender returns the sender selected by the otpSender config: mailer, file or log.
+// smtp is the former name of mailer, kept for existing configs.
+func newOtpSender(cfg *conf.Conf, logger logs.Logger, mailer Mailer) OtpSender {
+	switch cfg.OtpSender {
+	case "mailer", "smtp":
+		return &mailerOtpSender{mailer: mailer, cfg: cfg}
+	case "file":
+		return &fileOtpSender{path: cfg.OtpFile}
+	case "log":
+		return &logOtpSender{logger: logger}
+	default:
+		log.Fatal("Error loading otpSender: unknown value " + cfg.OtpSender)
+		return nil
+	}
+}
+
+// mailerOtpSender emails the code with the otp template
+type mailerOtpSender struct {
+	mailer Mailer
+	cfg    *conf.Conf
+}
+
+func (s *mailerOtpSender) Send(email, code string) error {
+	msg, err := Render(TemplateOtp, s.cfg.MailDefaultLocale, map[string]any{"code": code, "minutes": int(otpExpiry.Minutes())})
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	return s.mailer.Send(*msg)
+}
+
+// fileOtpSender appends codes to a file, for local development and tests
+type fileOtpSender struct {
+	path string
+}
+
+func (s *fileOtpSender) Send(email, code string) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s %s %s\n", time.Now().Format(time.RFC3339), email, code)
+	return err
+}
+
+// logOtpSender writes codes to the app log, for local development only
+type logOtpSender struct {
+	logger logs.Logger
+}
+
+func (s *logOtpSender) Send(email, code string) error {
+	s.logger.Info(uuid.Nil, "OTP for %s is %s", email, code)
+	return nil
+}
@@ -0,0 +1,65 @@
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+)
+
+// templates/<locale>/<name>.tmpl define the "subject", "text" and "html" blocks of each email,
+// the html block is wrapped by the "layout" of templates/layout.tmpl
+//
+//go:embed templates
+var templatesFS embed.FS
+
+const defaultLocale = "en"
+
+var rtlLocales = []string{"ar"}
+
+// Render builds the email of the template name in the locale, falling back to english when the locale has no such template
+func Render(name, locale string, data map[string]any) (*Message, error) {
+	locale = strings.ToLower(strings.SplitN(strings.SplitN(locale, "-", 2)[0], "_", 2)[0])
+	path := fmt.Sprintf("templates/%s/%s.tmpl", locale, name)
+	if _, err := templatesFS.Open(path); err != nil {
+		locale = defaultLocale
+		path = fmt.Sprintf("templates/%s/%s.tmpl", locale, name)
+	}
+
+	values := map[string]any{"lang": locale, "dir": "ltr"}
+	for _, rtl := range rtlLocales {
+		if locale == rtl {
+			values["dir"] = "rtl"
+		}
+	}
+	for k, v := range data {
+		values[k] = v
+	}
+
+	text, err := textTemplate.ParseFS(templatesFS, path)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmlTemplate.ParseFS(templatesFS, "templates/layout.tmpl", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", values); err != nil {
+		return nil, err
+	}
+	if err := text.ExecuteTemplate(&textBody, "text", values); err != nil {
+		return nil, err
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "layout", values); err != nil {
+		return nil, err
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
@@ -0,0 +1,63 @@
+package emails
+
+import (
+	"mahaam-api/utils/conf"
+	logs "mahaam-api/utils/log"
+
+	"github.com/google/uuid"
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/verify/v2"
+)
+
+// twilioEmailService sends and verifies otps with Twilio Verify over SendGrid
+type twilioEmailService struct {
+	cfg    *conf.Conf
+	client *twilio.RestClient
+	logger logs.Logger
+}
+
+func newTwilioEmailService(cfg *conf.Conf, logger logs.Logger) EmailService {
+	var client = twilio.NewRestClientWithParams(twilio.ClientParams{
+		Username: cfg.EmailAccountSID,
+		Password: cfg.EmailAuthToken,
+	})
+	return &twilioEmailService{cfg: cfg, client: client, logger: logger}
+}
+
+func (s *twilioEmailService) SendOtp(email string) (string, error) {
+	params := &twilioApi.CreateVerificationParams{}
+	params.SetTo(email)
+	params.SetChannel("email")
+
+	verification, err := s.client.VerifyV2.CreateVerification(s.cfg.EmailVerificationServiceSID, params)
+	if err != nil {
+		s.logger.Error(uuid.Nil, "Error sending OTP to %s: %v", email, err)
+		return "", err
+	}
+	if verification.Sid == nil {
+		return "", nil
+	}
+	return *verification.Sid, nil
+}
+
+func (s *twilioEmailService) CheckOtp(otp, sid, email string) (string, error) {
+	params := &twilioApi.CreateVerificationCheckParams{}
+	params.SetTo(email)
+	params.SetCode(otp)
+	params.SetVerificationSid(sid)
+
+	check, err := s.client.VerifyV2.CreateVerificationCheck(s.cfg.EmailVerificationServiceSID, params)
+	if err != nil {
+		s.logger.Info(uuid.Nil, "Error verifying OTP for %s: %v", email, err)
+		return "", err
+	}
+	if check.Status == nil {
+		return "", nil
+	}
+	return *check.Status, nil
+}
+
+// UseOtp has nothing to do, twilio uses up a code once it is checked as approved
+func (s *twilioEmailService) UseOtp(sid string) error {
+	return nil
+}
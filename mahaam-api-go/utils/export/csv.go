@@ -0,0 +1,50 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"mahaam-api/app/models"
+	"strconv"
+)
+
+var csvHeader = []string{"plan_title", "plan_type", "plan_status", "plan_starts", "plan_ends", "task_title", "task_done", "task_due"}
+
+// csvWriter writes one line per task, repeating its plan columns, and one line with empty task columns for plans without tasks
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+	writer.w.Write(csvHeader)
+	return writer
+}
+
+func (c *csvWriter) Write(row models.PlanTaskRow) error {
+	record := []string{
+		valueOf(row.PlanTitle),
+		row.PlanType,
+		row.PlanStatus,
+		valueOf(formatDate(row.Starts)),
+		valueOf(formatDate(row.Ends)),
+		"", "", "",
+	}
+	if row.TaskID != nil {
+		record[5] = *row.TaskTitle
+		record[6] = strconv.FormatBool(*row.TaskDone)
+		record[7] = valueOf(formatDate(row.TaskDue))
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func valueOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
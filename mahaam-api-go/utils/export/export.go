@@ -0,0 +1,75 @@
+package export
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"time"
+)
+
+// Writer encodes plans with their tasks as they are streamed from the db.
+// Rows of the same plan must come one after the other.
+type Writer interface {
+	Write(row models.PlanTaskRow) error
+	Close() error
+}
+
+func NewWriter(format models.ExportFormat, w io.Writer) Writer {
+	switch format {
+	case models.ExportFormatCSV:
+		return newCSVWriter(w)
+	case models.ExportFormatMarkdown:
+		return newMarkdownWriter(w)
+	case models.ExportFormatICS:
+		return NewICSWriter(w, "Mahaam")
+	default:
+		return newJSONWriter(w)
+	}
+}
+
+func ContentType(format models.ExportFormat) string {
+	switch format {
+	case models.ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case models.ExportFormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case models.ExportFormatICS:
+		return "text/calendar; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+func IsFormat(format string) bool {
+	switch models.ExportFormat(format) {
+	case models.ExportFormatJSON, models.ExportFormatCSV, models.ExportFormatMarkdown, models.ExportFormatICS:
+		return true
+	}
+	return false
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	date := t.Format(time.DateOnly)
+	return &date
+}
+
+func titleOf(title *string) string {
+	if title == nil || *title == "" {
+		return "Untitled"
+	}
+	return *title
+}
+
+// errWriter keeps the first write error so encoders can write without checking each call
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
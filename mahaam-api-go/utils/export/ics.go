@@ -0,0 +1,104 @@
+package export
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const icsDate = "20060102"
+
+// ICSWriter writes plans having starts or ends as all-day VEVENTs and tasks having a due date as VTODOs
+type ICSWriter struct {
+	out    errWriter
+	planID uuid.UUID
+	stamp  string
+}
+
+func NewICSWriter(w io.Writer, calendarName string) *ICSWriter {
+	writer := &ICSWriter{out: errWriter{w: w}, stamp: time.Now().UTC().Format("20060102T150405Z")}
+	writer.line("BEGIN:VCALENDAR")
+	writer.line("VERSION:2.0")
+	writer.line("PRODID:-//Mahaam//Mahaam API//EN")
+	writer.line("CALSCALE:GREGORIAN")
+	writer.line("X-WR-CALNAME:" + escapeText(calendarName))
+	return writer
+}
+
+func (i *ICSWriter) Write(row models.PlanTaskRow) error {
+	if row.PlanID != i.planID {
+		i.planID = row.PlanID
+		i.writeEvent(row)
+	}
+	if row.TaskID != nil && row.TaskDue != nil {
+		i.writeTodo(row)
+	}
+	return i.out.err
+}
+
+func (i *ICSWriter) writeEvent(row models.PlanTaskRow) {
+	if row.Starts == nil && row.Ends == nil {
+		return
+	}
+	starts, ends := row.Starts, row.Ends
+	if starts == nil {
+		starts = ends
+	}
+	if ends == nil || ends.Before(*starts) {
+		ends = starts
+	}
+	i.line("BEGIN:VEVENT")
+	i.line("UID:" + row.PlanID.String() + "@mahaam")
+	i.line("DTSTAMP:" + i.stamp)
+	i.line("DTSTART;VALUE=DATE:" + starts.Format(icsDate))
+	// DTEND is exclusive for all-day events
+	i.line("DTEND;VALUE=DATE:" + ends.AddDate(0, 0, 1).Format(icsDate))
+	i.line("SUMMARY:" + escapeText(titleOf(row.PlanTitle)))
+	i.line("END:VEVENT")
+}
+
+func (i *ICSWriter) writeTodo(row models.PlanTaskRow) {
+	status := "NEEDS-ACTION"
+	if *row.TaskDone {
+		status = "COMPLETED"
+	}
+	i.line("BEGIN:VTODO")
+	i.line("UID:" + row.TaskID.String() + "@mahaam")
+	i.line("DTSTAMP:" + i.stamp)
+	i.line("DUE;VALUE=DATE:" + row.TaskDue.Format(icsDate))
+	i.line("SUMMARY:" + escapeText(*row.TaskTitle))
+	i.line("STATUS:" + status)
+	i.line("RELATED-TO:" + row.PlanID.String() + "@mahaam")
+	i.line("END:VTODO")
+}
+
+func (i *ICSWriter) Close() error {
+	i.line("END:VCALENDAR")
+	return i.out.err
+}
+
+// line writes a content line folded at 75 octets as RFC 5545 requires, without splitting utf-8 characters
+func (i *ICSWriter) line(content string) {
+	for len(content) > 75 {
+		cut := 75
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		i.out.write(content[:cut] + "\r\n")
+		content = " " + content[cut:]
+	}
+	i.out.write(content + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return icsEscaper.Replace(s)
+}
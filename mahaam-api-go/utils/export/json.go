@@ -0,0 +1,70 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mahaam-api/app/models"
+
+	"github.com/google/uuid"
+)
+
+// jsonWriter writes models.ExportData, holding only the tasks of the current plan in memory
+type jsonWriter struct {
+	out     errWriter
+	planID  uuid.UUID
+	plan    *models.ExportPlan
+	written int
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	writer := &jsonWriter{out: errWriter{w: w}}
+	writer.out.write(fmt.Sprintf(`{"version":%d,"plans":[`, models.ExportVersion))
+	return writer
+}
+
+func (j *jsonWriter) Write(row models.PlanTaskRow) error {
+	if j.plan == nil || row.PlanID != j.planID {
+		j.flush()
+		j.planID = row.PlanID
+		j.plan = &models.ExportPlan{
+			Title:  row.PlanTitle,
+			Type:   row.PlanType,
+			Status: row.PlanStatus,
+			Starts: formatDate(row.Starts),
+			Ends:   formatDate(row.Ends),
+			Tasks:  []models.ExportTask{},
+		}
+	}
+	if row.TaskID != nil {
+		j.plan.Tasks = append(j.plan.Tasks, models.ExportTask{
+			Title: *row.TaskTitle,
+			Done:  *row.TaskDone,
+			Due:   formatDate(row.TaskDue),
+		})
+	}
+	return j.out.err
+}
+
+func (j *jsonWriter) flush() {
+	if j.plan == nil {
+		return
+	}
+	data, err := json.Marshal(j.plan)
+	if err != nil {
+		j.out.err = err
+		return
+	}
+	if j.written > 0 {
+		j.out.write(",")
+	}
+	j.out.write(string(data))
+	j.written++
+	j.plan = nil
+}
+
+func (j *jsonWriter) Close() error {
+	j.flush()
+	j.out.write("]}")
+	return j.out.err
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"io"
+	"mahaam-api/app/models"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// markdownWriter writes each plan as a heading followed by its tasks as a checklist
+type markdownWriter struct {
+	out    errWriter
+	planID uuid.UUID
+	plans  int
+}
+
+func newMarkdownWriter(w io.Writer) *markdownWriter {
+	return &markdownWriter{out: errWriter{w: w}}
+}
+
+func (m *markdownWriter) Write(row models.PlanTaskRow) error {
+	if m.plans == 0 || row.PlanID != m.planID {
+		if m.plans > 0 {
+			m.out.write("\n")
+		}
+		m.planID = row.PlanID
+		m.plans++
+		m.out.write("# " + titleOf(row.PlanTitle) + "\n\n")
+		dates := make([]string, 0, 2)
+		if starts := formatDate(row.Starts); starts != nil {
+			dates = append(dates, "Starts: "+*starts)
+		}
+		if ends := formatDate(row.Ends); ends != nil {
+			dates = append(dates, "Ends: "+*ends)
+		}
+		if len(dates) > 0 {
+			m.out.write(strings.Join(dates, " · ") + "\n\n")
+		}
+	}
+	if row.TaskID != nil {
+		check := "[ ]"
+		if *row.TaskDone {
+			check = "[x]"
+		}
+		line := "- " + check + " " + *row.TaskTitle
+		if due := formatDate(row.TaskDue); due != nil {
+			line += " (due " + *due + ")"
+		}
+		m.out.write(line + "\n")
+	}
+	return m.out.err
+}
+
+func (m *markdownWriter) Close() error {
+	return m.out.err
+}
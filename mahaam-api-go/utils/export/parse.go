@@ -0,0 +1,199 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mahaam-api/app/models"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Parse reads plans from our own json export, markdown checklists or csv files of other todo apps
+func Parse(format models.ExportFormat, r io.Reader) ([]models.ExportPlan, error) {
+	switch format {
+	case models.ExportFormatJSON:
+		return parseJSON(r)
+	case models.ExportFormatCSV:
+		return parseCSV(r)
+	case models.ExportFormatMarkdown:
+		return parseMarkdown(r)
+	}
+	return nil, fmt.Errorf("format %s cannot be imported", format)
+}
+
+const importedPlanTitle = "Imported"
+
+func parseJSON(r io.Reader) ([]models.ExportPlan, error) {
+	var data models.ExportData
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, errors.New("invalid json: " + err.Error())
+	}
+	if data.Version > models.ExportVersion {
+		return nil, fmt.Errorf("export version %d is not supported", data.Version)
+	}
+	return data.Plans, nil
+}
+
+var (
+	headingLine = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+	taskLine    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(?:\[([ xX])\]\s+)?(.+)$`)
+	dueSuffix   = regexp.MustCompile(`\s+\(due (\d{4}-\d{2}-\d{2})\)$`)
+	datesLine   = regexp.MustCompile(`^(?:Starts: (\d{4}-\d{2}-\d{2}))?(?: · )?(?:Ends: (\d{4}-\d{2}-\d{2}))?$`)
+)
+
+// parseMarkdown reads headings as plans and list items as tasks, "- [x]" items being done.
+// Items before any heading go to a plan named Imported.
+func parseMarkdown(r io.Reader) ([]models.ExportPlan, error) {
+	plans := make([]models.ExportPlan, 0)
+	current := func() *models.ExportPlan {
+		if len(plans) == 0 {
+			title := importedPlanTitle
+			plans = append(plans, models.ExportPlan{Title: &title, Tasks: []models.ExportTask{}})
+		}
+		return &plans[len(plans)-1]
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			title := strings.TrimSpace(m[1])
+			plans = append(plans, models.ExportPlan{Title: &title, Tasks: []models.ExportTask{}})
+			continue
+		}
+		if m := taskLine.FindStringSubmatch(line); m != nil {
+			task := models.ExportTask{Title: strings.TrimSpace(m[2]), Done: strings.EqualFold(m[1], "x")}
+			if due := dueSuffix.FindStringSubmatch(task.Title); due != nil {
+				task.Due = &due[1]
+				task.Title = strings.TrimSuffix(task.Title, due[0])
+			}
+			plan := current()
+			plan.Tasks = append(plan.Tasks, task)
+			continue
+		}
+		if m := datesLine.FindStringSubmatch(line); m != nil && len(plans) > 0 {
+			if m[1] != "" {
+				plans[len(plans)-1].Starts = &m[1]
+			}
+			if m[2] != "" {
+				plans[len(plans)-1].Ends = &m[2]
+			}
+		}
+		// other lines, like notes or quotes, are ignored
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// csvColumns maps our export columns and common column names of other todo apps
+var csvColumns = map[string][]string{
+	"plan":        {"plan_title", "plan", "list", "list name", "project", "folder"},
+	"plan_type":   {"plan_type"},
+	"plan_status": {"plan_status"},
+	"plan_starts": {"plan_starts", "starts", "start date"},
+	"plan_ends":   {"plan_ends", "ends", "end date"},
+	"task":        {"task_title", "task", "title", "content", "todo", "name"},
+	"done":        {"task_done", "done", "completed", "is_completed", "status"},
+	"due":         {"task_due", "due", "due_date", "due date", "date"},
+}
+
+// parseCSV groups rows by their plan column, rows without a plan column go to a plan named Imported
+func parseCSV(r io.Reader) ([]models.ExportPlan, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("invalid csv: " + err.Error())
+	}
+
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for column, aliases := range csvColumns {
+			if _, found := index[column]; !found && slices.Contains(aliases, name) {
+				index[column] = i
+			}
+		}
+	}
+	if _, ok := index["task"]; !ok {
+		if _, ok := index["plan"]; !ok {
+			return nil, errors.New("csv should have a task_title or plan_title column")
+		}
+	}
+
+	plans := make([]models.ExportPlan, 0)
+	planIndex := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv at line %d: %v", line, err)
+		}
+		value := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		planTitle := value("plan")
+		if planTitle == "" {
+			planTitle = importedPlanTitle
+		}
+		i, ok := planIndex[planTitle]
+		if !ok {
+			i = len(plans)
+			planIndex[planTitle] = i
+			title := planTitle
+			plans = append(plans, models.ExportPlan{
+				Title:  &title,
+				Type:   value("plan_type"),
+				Status: value("plan_status"),
+				Starts: dateOf(value("plan_starts")),
+				Ends:   dateOf(value("plan_ends")),
+				Tasks:  []models.ExportTask{},
+			})
+		}
+		if taskTitle := value("task"); taskTitle != "" {
+			plans[i].Tasks = append(plans[i].Tasks, models.ExportTask{
+				Title: taskTitle,
+				Done:  isDone(value("done")),
+				Due:   dateOf(value("due")),
+			})
+		}
+	}
+	return plans, nil
+}
+
+func isDone(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes", "y", "x", "done", "completed", "complete":
+		return true
+	}
+	return false
+}
+
+// dateOf keeps the date part of values like 2024-05-01 or 2024-05-01T10:00:00Z, and drops values in other formats
+func dateOf(value string) *string {
+	if len(value) < len(time.DateOnly) {
+		return nil
+	}
+	date := value[:len(time.DateOnly)]
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return nil
+	}
+	return &date
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"mahaam-api/app/models"
+	"mahaam-api/utils/conf"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecommendedVersionHeader hints apps below the recommended version of their store to upgrade
+const RecommendedVersionHeader = "x-app-recommended-version"
+
+// version is a semantic version, a missing minor or patch is 0 and build metadata is ignored
+type version struct {
+	core       [3]int
+	preRelease []string
+}
+
+func parseVersion(value string) (version, error) {
+	var v version
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	value, _, _ = strings.Cut(value, "+")
+	value, preRelease, hasPreRelease := strings.Cut(value, "-")
+	parts := strings.Split(value, ".")
+	if len(parts) > 3 {
+		return v, errors.New("invalid version " + value)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, errors.New("invalid version " + value)
+		}
+		v.core[i] = n
+	}
+	if hasPreRelease {
+		if preRelease == "" {
+			return v, errors.New("invalid version " + value)
+		}
+		v.preRelease = strings.Split(preRelease, ".")
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or 1, a pre-release is lower than its release like 2.0.0-beta.2 < 2.0.0
+func (v version) compare(o version) int {
+	for i := range v.core {
+		if v.core[i] != o.core[i] {
+			return sign(v.core[i] - o.core[i])
+		}
+	}
+	if len(v.preRelease) == 0 || len(o.preRelease) == 0 {
+		return sign(len(o.preRelease) - len(v.preRelease))
+	}
+	for i := 0; i < len(v.preRelease) && i < len(o.preRelease); i++ {
+		if c := comparePreRelease(v.preRelease[i], o.preRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.preRelease) - len(o.preRelease))
+}
+
+// comparePreRelease compares numeric identifiers numerically and lower than alphanumeric ones
+func comparePreRelease(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return sign(an - bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+type storePolicy struct {
+	minimum     *version
+	recommended *version
+	info        models.UpgradeInfo
+}
+
+// newAppVersionCheck returns the check of the auth middleware that rejects apps below the minimum version of their store
+// with 426 and the upgrade info, and sets RecommendedVersionHeader for apps below the recommended version.
+// Stores without a policy are not checked.
+func newAppVersionCheck(cfg *conf.Conf) func(c *gin.Context) {
+	policies := map[string]storePolicy{}
+	for _, v := range cfg.AppVersions {
+		policy := storePolicy{info: models.UpgradeInfo{Store: v.Store, MinimumVersion: v.MinimumVersion,
+			RecommendedVersion: v.RecommendedVersion, UpgradeURL: v.UpgradeURL}}
+		if v.MinimumVersion != "" {
+			policy.minimum = mustParseVersion(v.Store, v.MinimumVersion)
+		}
+		if v.RecommendedVersion != "" {
+			policy.recommended = mustParseVersion(v.Store, v.RecommendedVersion)
+		}
+		if policy.minimum != nil && policy.recommended != nil && policy.recommended.compare(*policy.minimum) < 0 {
+			log.Fatal("Error loading app versions of " + v.Store + ": recommendedVersion is below minimumVersion")
+		}
+		policies[strings.ToLower(v.Store)] = policy
+	}
+
+	return func(c *gin.Context) {
+		policy, ok := policies[strings.ToLower(c.GetHeader("x-app-store"))]
+		if !ok {
+			return
+		}
+		// a version that cannot be compared is treated as too old when there is a minimum
+		appVersion, err := parseVersion(c.GetHeader("x-app-version"))
+		if policy.minimum != nil && (err != nil || appVersion.compare(*policy.minimum) < 0) {
+			info := policy.info
+			panic(models.UpgradeRequiredError(&info))
+		}
+		if err == nil && policy.recommended != nil && appVersion.compare(*policy.recommended) < 0 {
+			c.Header(RecommendedVersionHeader, policy.info.RecommendedVersion)
+		}
+	}
+}
+
+func mustParseVersion(store, value string) *version {
+	v, err := parseVersion(value)
+	if err != nil {
+		log.Fatal("Error loading app versions of " + store + ": " + err.Error())
+	}
+	return &v
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		core       [3]int
+		preRelease []string
+		wantErr    bool
+	}{
+		{"full version", "1.2.3", [3]int{1, 2, 3}, nil, false},
+		{"v prefix", "v1.2.3", [3]int{1, 2, 3}, nil, false},
+		{"missing patch", "1.2", [3]int{1, 2, 0}, nil, false},
+		{"missing minor and patch", "1", [3]int{1, 0, 0}, nil, false},
+		{"spaces", " 1.2.3 ", [3]int{1, 2, 3}, nil, false},
+		{"pre-release", "2.0.0-beta.2", [3]int{2, 0, 0}, []string{"beta", "2"}, false},
+		{"build metadata ignored", "2.0.0+build.7", [3]int{2, 0, 0}, nil, false},
+		{"pre-release with build metadata", "v2.0.0-rc.1+build.7", [3]int{2, 0, 0}, []string{"rc", "1"}, false},
+		{"empty", "", [3]int{}, nil, true},
+		{"too many parts", "1.2.3.4", [3]int{}, nil, true},
+		{"not a number", "1.x.3", [3]int{}, nil, true},
+		{"negative", "1.-2.3", [3]int{}, nil, true},
+		{"empty pre-release", "1.2.3-", [3]int{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVersion(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVersion(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.core != tt.core || !slices.Equal(got.preRelease, tt.preRelease) {
+				t.Errorf("parseVersion(%q) = %v %v, want %v %v", tt.value, got.core, got.preRelease, tt.core, tt.preRelease)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"equal", "1.2.3", "1.2.3", 0},
+		{"v prefix equal", "v1.2.3", "1.2.3", 0},
+		{"missing patch equal", "1.2", "1.2.0", 0},
+		{"missing minor and patch equal", "2", "2.0.0", 0},
+		{"build metadata equal", "1.2.3+1", "1.2.3+2", 0},
+		{"major", "2.0.0", "1.9.9", 1},
+		{"minor", "1.2.0", "1.10.0", -1},
+		{"patch", "1.2.3", "1.2.4", -1},
+		{"pre-release below release", "2.0.0-beta.2", "2.0.0", -1},
+		{"release above pre-release", "2.0.0", "2.0.0-rc.1", 1},
+		{"pre-release of the next patch above release", "2.0.1-alpha", "2.0.0", 1},
+		{"numeric pre-release numerically", "1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"numeric below alphanumeric", "1.0.0-1", "1.0.0-alpha", -1},
+		{"alphanumeric lexically", "1.0.0-alpha", "1.0.0-beta", -1},
+		{"shorter pre-release below longer", "1.0.0-alpha", "1.0.0-alpha.1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseVersion(tt.a)
+			if err != nil {
+				t.Fatalf("parseVersion(%q) error = %v", tt.a, err)
+			}
+			b, err := parseVersion(tt.b)
+			if err != nil {
+				t.Fatalf("parseVersion(%q) error = %v", tt.b, err)
+			}
+			if got := a.compare(b); got != tt.want {
+				t.Errorf("%s compare %s = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
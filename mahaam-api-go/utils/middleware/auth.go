@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"net/http"
 	"strings"
+	"time"
+
+	"mahaam-api/app/repo"
+	"mahaam-api/utils/conf"
 
 	logs "mahaam-api/utils/log"
 	token "mahaam-api/utils/token"
@@ -22,7 +26,11 @@ func (w *responseWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
-func AuthMiddleware(tokenService *token.TokenService, logger logs.Logger) gin.HandlerFunc {
+// deviceSeenInterval limits how often the last seen time and ip of a device are written
+const deviceSeenInterval = 5 * time.Minute
+
+func AuthMiddleware(cfg *conf.Conf, tokenService *token.TokenService, deviceRepo repo.DeviceRepo, logger logs.Logger) gin.HandlerFunc {
+	checkAppVersion := newAppVersionCheck(cfg)
 	return func(c *gin.Context) {
 
 		trafficId := c.Value("trafficID").(uuid.UUID)
@@ -33,19 +41,31 @@ func AuthMiddleware(tokenService *token.TokenService, logger logs.Logger) gin.Ha
 			return
 		}
 
-		// Validate headers
+		// Calendar feeds and takeout downloads are fetched by calendar apps and browsers without app headers or jwt,
+		// the secret token or signature in their url is checked by the handler
+		path := strings.ReplaceAll(pathBase, "/mahaam-api", "")
+		for _, publicPath := range []string{"/calendar/", "/takeout/"} {
+			if strings.HasPrefix(path, publicPath) {
+				c.Next()
+				return
+			}
+		}
+
+		// Validate headers, personal access tokens are used by scripts rather than the app
 		appStore := c.GetHeader("x-app-store")
 		appVersion := c.GetHeader("x-app-version")
-		if appStore == "" || appVersion == "" {
+		if !token.IsAccessToken(c) && (appStore == "" || appVersion == "") {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, "Required headers not exists")
 			logger.Error(trafficId, "Required headers not exists")
 			return
 		}
+		// outdated apps are told to upgrade before their tokens are checked
+		if !token.IsAccessToken(c) {
+			checkAppVersion(c)
+		}
 
 		// Check bypass paths
-		path := c.Request.URL.Path
-		path = strings.ReplaceAll(path, "/mahaam-api", "")
-		bypassAuthPaths := []string{"/swagger", "/health", "/users/create", "/audit/info", "/audit/error"}
+		bypassAuthPaths := []string{"/swagger", "/health", "/users/create", "/users/rotate-token", "/audit/info", "/audit/error"}
 		requiresAuth := true
 		for _, bypassPath := range bypassAuthPaths {
 			if strings.HasPrefix(path, bypassPath) {
@@ -56,7 +76,24 @@ func AuthMiddleware(tokenService *token.TokenService, logger logs.Logger) gin.Ha
 
 		// Authenticate if required
 		var userId, deviceId uuid.UUID
-		if requiresAuth {
+		if requiresAuth && token.IsAccessToken(c) {
+			// personal access tokens are not bound to a device, their scopes limit what they can reach
+			var accessTokenId uuid.UUID
+			var err error
+			userId, accessTokenId, err = (*tokenService).ParseAccessToken(c)
+			if err == token.ErrInsufficientScope {
+				c.AbortWithStatusJSON(http.StatusForbidden, "insufficient token scope")
+				logger.Error(trafficId, err.Error())
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, "invalid access token")
+				logger.Error(trafficId, err.Error())
+				return
+			}
+			c.Set("userId", userId)
+			c.Set("accessTokenId", accessTokenId)
+		} else if requiresAuth {
 			var err error
 			userId, deviceId, err = (*tokenService).Parse(c)
 			if err != nil {
@@ -66,6 +103,7 @@ func AuthMiddleware(tokenService *token.TokenService, logger logs.Logger) gin.Ha
 			}
 			c.Set("userId", userId)
 			c.Set("deviceId", deviceId)
+			deviceRepo.UpdateLastSeen(deviceId, c.ClientIP(), deviceSeenInterval)
 		}
 
 		c.Next()
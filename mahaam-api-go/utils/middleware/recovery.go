@@ -4,6 +4,7 @@ import (
 	"mahaam-api/app/models"
 	logs "mahaam-api/utils/log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -21,7 +22,12 @@ func RecoveryMiddleware(logger logs.Logger) gin.HandlerFunc {
 
 				if e, ok := err.(*models.Err); ok {
 					logger.Error(trafficID, e.Error())
-					if e.Key == "" {
+					if e.RetryAfter > 0 {
+						c.Header("Retry-After", strconv.Itoa(e.RetryAfter))
+						c.JSON(e.Code, gin.H{"error": e.Message, "key": e.Key, "retryAfter": e.RetryAfter})
+					} else if e.Upgrade != nil {
+						c.JSON(e.Code, gin.H{"error": e.Message, "key": e.Key, "upgrade": e.Upgrade})
+					} else if e.Key == "" {
 						c.JSON(e.Code, e.Message)
 					} else {
 						c.JSON(e.Code, gin.H{"error": e.Message, "key": e.Key})
@@ -40,6 +40,13 @@ func TrafficMiddleware(trafficRepo repo.TrafficRepo, cfg *conf.Conf, logger logs
 			// Log traffic (skip for swagger, health, audit paths)
 			path := c.Request.URL.Path
 			path = strings.ReplaceAll(path, "/mahaam-api", "")
+			// calendar feed tokens and takeout signatures are credentials, keep them out of the traffic log
+			query := c.Request.URL.RawQuery
+			if strings.HasPrefix(path, "/calendar/") {
+				path = "/calendar/{token}"
+			} else if strings.HasPrefix(path, "/takeout/") {
+				query = ""
+			}
 			if !strings.HasPrefix(path, "/swagger") && !strings.EqualFold(path, "/health") && !strings.HasPrefix(path, "/audit") {
 
 				code := c.Writer.Status()
@@ -74,7 +81,7 @@ func TrafficMiddleware(trafficRepo repo.TrafficRepo, cfg *conf.Conf, logger logs
 					ID:       trafficId,
 					HealthID: conf.Env().HealthID,
 					Method:   c.Request.Method,
-					Path:     path + c.Request.URL.RawQuery,
+					Path:     path + query,
 					Code:     code,
 					Elapsed:  elapsed,
 					Headers:  headersStr,
@@ -0,0 +1,172 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"mahaam-api/utils/conf"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is an OpenID Connect provider serving discovery, jwks and a token endpoint returning idToken
+type mockIdP struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+	// form is the last token request
+	form url.Values
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JwksURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []jwk{{
+			Kty: "RSA",
+			Kid: "k1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.form = r.PostForm
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            idp.server.URL,
+			"aud":            "mahaam",
+			"sub":            "user-1",
+			"email":          "a@mahaam.dev",
+			"email_verified": true,
+			"name":           "A",
+			"nonce":          "n1",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		}
+	}
+	tests := []struct {
+		name    string
+		claims  func(jwt.MapClaims)
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "email_verified as string", claims: func(c jwt.MapClaims) { c["email_verified"] = "true" }},
+		{name: "azp of the client", claims: func(c jwt.MapClaims) { c["aud"] = []string{"mahaam", "other"}; c["azp"] = "mahaam" }},
+		{name: "another issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, wantErr: "invalid id token"},
+		{name: "another audience", claims: func(c jwt.MapClaims) { c["aud"] = "other" }, wantErr: "invalid id token"},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: "invalid id token"},
+		{name: "no expiry", claims: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: "invalid id token"},
+		{name: "another nonce", claims: func(c jwt.MapClaims) { c["nonce"] = "n2" }, wantErr: "invalid id token nonce"},
+		{name: "azp of another client", claims: func(c jwt.MapClaims) { c["azp"] = "other" }, wantErr: "invalid id token authorized party"},
+		{name: "several audiences without azp", claims: func(c jwt.MapClaims) { c["aud"] = []string{"mahaam", "other"} }, wantErr: "invalid id token authorized party"},
+		{name: "no subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }, wantErr: "id token has no subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			idp.idToken = idp.sign(t, claims)
+			p := NewProviders(&conf.Conf{OidcProviders: []conf.OidcProvider{{
+				Name: "mock", Issuer: idp.server.URL, ClientID: "mahaam", RedirectURL: "mahaam://oidc",
+			}}})["mock"]
+
+			identity, err := p.Exchange("code-1", "verifier-1", "n1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Identity{Subject: "user-1", Email: "a@mahaam.dev", EmailVerified: true, Name: "A"}
+			if *identity != want {
+				t.Errorf("identity = %+v, want %+v", *identity, want)
+			}
+			if idp.form.Get("code") != "code-1" || idp.form.Get("code_verifier") != "verifier-1" || idp.form.Get("client_id") != "mahaam" {
+				t.Errorf("token request = %v", idp.form)
+			}
+		})
+	}
+}
+
+func TestAuthorizationURL(t *testing.T) {
+	idp := newMockIdP(t)
+	p := NewProviders(&conf.Conf{OidcProviders: []conf.OidcProvider{{
+		Name: "mock", Issuer: idp.server.URL, ClientID: "mahaam", RedirectURL: "mahaam://oidc",
+	}}})["mock"]
+
+	authURL, err := p.AuthorizationURL("state-1", "n1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "mahaam",
+		"redirect_uri":          "mahaam://oidc",
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "n1",
+		"code_challenge":        codeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	}
+	if u.Path != "/authorize" {
+		t.Errorf("path = %s, want /authorize", u.Path)
+	}
+	for key, value := range want {
+		if q.Get(key) != value {
+			t.Errorf("%s = %q, want %q", key, q.Get(key), value)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package security
+
+import (
+	"mahaam-api/app/models"
+	"net/http"
+	"testing"
+)
+
+func TestScopeAllows(t *testing.T) {
+	routeScopes = map[string][]string{}
+	AllowScopes(http.MethodGet, "/mahaam-api/plans", models.AccessTokenScopeRead, models.AccessTokenScopeTasks, models.AccessTokenScopePlans)
+	AllowScopes(http.MethodPost, "/mahaam-api/plans", models.AccessTokenScopePlans)
+	AllowScopes(http.MethodPost, "/mahaam-api/plans/:planId/tasks/", models.AccessTokenScopeTasks)
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		method   string
+		fullPath string
+		want     bool
+	}{
+		{"read scope reads", []string{"read"}, http.MethodGet, "/mahaam-api/plans", true},
+		{"tasks scope reads", []string{"tasks"}, http.MethodGet, "/mahaam-api/plans", true},
+		{"read scope cannot write", []string{"read"}, http.MethodPost, "/mahaam-api/plans", false},
+		{"plans scope writes plans", []string{"plans"}, http.MethodPost, "/mahaam-api/plans", true},
+		{"plans scope cannot write tasks", []string{"plans"}, http.MethodPost, "/mahaam-api/plans/:planId/tasks/", false},
+		{"one of many scopes", []string{"read", "tasks"}, http.MethodPost, "/mahaam-api/plans/:planId/tasks/", true},
+		{"no scopes", []string{}, http.MethodGet, "/mahaam-api/plans", false},
+		{"undeclared route", []string{"read", "tasks", "plans"}, http.MethodGet, "/mahaam-api/users/devices", false},
+		{"undeclared method of a route", []string{"plans"}, http.MethodDelete, "/mahaam-api/plans", false},
+		{"unmatched route", []string{"read"}, http.MethodGet, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scopeAllows(tt.scopes, tt.method, tt.fullPath); got != tt.want {
+				t.Errorf("scopeAllows(%v, %s, %s) = %v, want %v", tt.scopes, tt.method, tt.fullPath, got, tt.want)
+			}
+		})
+	}
+}
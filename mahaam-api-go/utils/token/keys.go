@@ -0,0 +1,198 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"mahaam-api/utils/conf"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	KeyStatusActive  = "active"
+	KeyStatusVerify  = "verify"
+	KeyStatusRetired = "retired"
+
+	legacyKid = "legacy"
+)
+
+// JWK is the public part of a signing key as published in /.well-known/jwks.json
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	kid     string
+	alg     string
+	status  string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+type keySet struct {
+	active *signingKey
+	byKid  map[string]*signingKey
+	jwks   JWKSet
+}
+
+// loadKeys builds the key set from cfg.JwtKeys, without keys it falls back to a single HS256 key using cfg.TokenSecretKey
+func loadKeys(cfg *conf.Conf) (*keySet, error) {
+	set := &keySet{byKid: map[string]*signingKey{}, jwks: JWKSet{Keys: []JWK{}}}
+	if len(cfg.JwtKeys) == 0 {
+		key := &signingKey{kid: legacyKid, alg: "HS256", status: KeyStatusActive, method: jwt.SigningMethodHS256,
+			private: []byte(cfg.TokenSecretKey), public: []byte(cfg.TokenSecretKey)}
+		set.active = key
+		set.byKid[key.kid] = key
+		return set, nil
+	}
+
+	hasSymmetric := false
+	for _, k := range cfg.JwtKeys {
+		if k.Kid == "" {
+			return nil, errors.New("jwt key without kid")
+		}
+		if _, exists := set.byKid[k.Kid]; exists {
+			return nil, fmt.Errorf("duplicate jwt key %s", k.Kid)
+		}
+		key := &signingKey{kid: k.Kid, alg: k.Alg, status: k.Status}
+		switch k.Status {
+		case KeyStatusActive:
+			if set.active != nil {
+				return nil, errors.New("only one jwt key can be active")
+			}
+			set.active = key
+		case KeyStatusVerify:
+		case KeyStatusRetired:
+			// retired keys are rejected and never loaded
+			set.byKid[key.kid] = key
+			continue
+		default:
+			return nil, fmt.Errorf("jwt key %s has invalid status %q", k.Kid, k.Status)
+		}
+
+		if k.Alg == "HS256" && hasSymmetric {
+			return nil, errors.New("only one HS256 jwt key is allowed")
+		}
+		hasSymmetric = hasSymmetric || k.Alg == "HS256"
+		if err := key.load(k, cfg); err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", k.Kid, err)
+		}
+		set.byKid[key.kid] = key
+		if jwk, ok := key.jwk(); ok {
+			set.jwks.Keys = append(set.jwks.Keys, jwk)
+		}
+	}
+	if set.active == nil {
+		return nil, errors.New("no active jwt key")
+	}
+	return set, nil
+}
+
+func (k *signingKey) load(c conf.JwtKey, cfg *conf.Conf) error {
+	if k.alg == "HS256" {
+		if cfg.TokenSecretKey == "" {
+			return errors.New("HS256 requires tokenSecretKey")
+		}
+		k.method = jwt.SigningMethodHS256
+		k.private, k.public = []byte(cfg.TokenSecretKey), []byte(cfg.TokenSecretKey)
+		return nil
+	}
+
+	data := []byte(c.PrivateKey)
+	if c.PrivateKeyFile != "" {
+		content, err := os.ReadFile(c.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		data = content
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return err
+		}
+		parsed = rsaKey
+	}
+
+	switch k.alg {
+	case "RS256":
+		private, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return errors.New("RS256 requires an RSA private key")
+		}
+		k.method, k.private, k.public = jwt.SigningMethodRS256, private, &private.PublicKey
+	case "EdDSA":
+		private, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 private key")
+		}
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, private, private.Public()
+	default:
+		return fmt.Errorf("unsupported alg %q", k.alg)
+	}
+	return nil
+}
+
+// jwk returns the public key in JWK form, symmetric keys are never published
+func (k *signingKey) jwk() (JWK, bool) {
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", Kid: k.kid, Use: "sig", Alg: k.alg,
+			N: base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())}, true
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Kid: k.kid, Use: "sig", Alg: k.alg, Crv: "Ed25519",
+			X: base64.RawURLEncoding.EncodeToString(public)}, true
+	}
+	return JWK{}, false
+}
+
+// verificationKey resolves the key of a token by its kid, tokens without kid are legacy HS256 tokens
+func (s *keySet) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKid
+		for _, k := range s.byKid {
+			if k.alg == "HS256" {
+				kid = k.kid
+			}
+		}
+	}
+	key, ok := s.byKid[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if key.status == KeyStatusRetired {
+		return nil, errors.New("signing key retired")
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
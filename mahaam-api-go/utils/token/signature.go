@@ -0,0 +1,18 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sign returns the hex hmac-sha256 of value, used to sign short lived urls
+func Sign(secret, value string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret, value, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, value)), []byte(signature))
+}
@@ -2,6 +2,7 @@ package security
 
 import (
 	"errors"
+	"log"
 	"mahaam-api/app/repo"
 	"mahaam-api/utils/conf"
 	"strings"
@@ -10,27 +11,45 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type TokenService interface {
 	Parse(r *gin.Context) (uuid.UUID, uuid.UUID, error)
-	Create(userId, deviceId uuid.UUID) (string, error)
+	HasSession(deviceId uuid.UUID) bool
+	CreateSession(tx *sqlx.Tx, userId, deviceId uuid.UUID) (string, string, error)
+	Refresh(refreshToken string) (uuid.UUID, uuid.UUID, string, string, error)
+	ParseAccessToken(r *gin.Context) (uuid.UUID, uuid.UUID, error)
+	JWKS() JWKSet
 }
 
 type tokenService struct {
-	deviceRepo repo.DeviceRepo
-	userRepo   repo.UserRepo
-	cfg        *conf.Conf
+	deviceRepo       repo.DeviceRepo
+	userRepo         repo.UserRepo
+	refreshTokenRepo repo.RefreshTokenRepo
+	accessTokenRepo  repo.AccessTokenRepo
+	cfg              *conf.Conf
+	keys             *keySet
 }
 
-func NewTokenService(deviceRepo repo.DeviceRepo, userRepo repo.UserRepo, cfg *conf.Conf) TokenService {
+func NewTokenService(deviceRepo repo.DeviceRepo, userRepo repo.UserRepo, refreshTokenRepo repo.RefreshTokenRepo, accessTokenRepo repo.AccessTokenRepo, cfg *conf.Conf) TokenService {
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		log.Fatal("Error loading jwt keys: " + err.Error())
+	}
 	return &tokenService{
-		deviceRepo: deviceRepo,
-		userRepo:   userRepo,
-		cfg:        cfg,
+		keys:             keys,
+		deviceRepo:       deviceRepo,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		accessTokenRepo:  accessTokenRepo,
+		cfg:              cfg,
 	}
 }
 
+// ErrRefreshTokenReused is returned when a rotated refresh token is used again, its whole family is revoked
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
 func (s *tokenService) Parse(r *gin.Context) (uuid.UUID, uuid.UUID, error) {
 	authorization := r.GetHeader("Authorization")
 	if authorization == "" {
@@ -72,6 +91,16 @@ func (s *tokenService) Parse(r *gin.Context) (uuid.UUID, uuid.UUID, error) {
 		}
 	}
 
+	var familyId uuid.UUID
+	if familyIdStr, ok := claims["familyId"].(string); ok {
+		if familyId, err = uuid.Parse(familyIdStr); err != nil {
+			return uuid.Nil, uuid.Nil, errors.New("familyId is not valid")
+		}
+	}
+	if !s.refreshTokenRepo.IsSessionActive(deviceId, familyId) {
+		return uuid.Nil, uuid.Nil, errors.New("session revoked")
+	}
+
 	user := s.userRepo.GetOne(userId)
 	if user == nil || user.ID != userId {
 		panic("user not found")
@@ -80,17 +109,86 @@ func (s *tokenService) Parse(r *gin.Context) (uuid.UUID, uuid.UUID, error) {
 	return userId, deviceId, nil
 }
 
-func (s *tokenService) Create(userId, deviceId uuid.UUID) (string, error) {
+// HasSession tells whether the device ever started a session, from then on its access tokens without a family are rejected
+func (s *tokenService) HasSession(deviceId uuid.UUID) bool {
+	return !s.refreshTokenRepo.IsSessionActive(deviceId, uuid.Nil)
+}
+
+// CreateSession starts a new session of the device, ending its previous ones,
+// and returns its access token and refresh token
+func (s *tokenService) CreateSession(tx *sqlx.Tx, userId, deviceId uuid.UUID) (string, string, error) {
+	s.refreshTokenRepo.RevokeDevice(tx, deviceId)
+	familyId := uuid.New()
+	refreshToken := NewOpaqueToken()
+	s.refreshTokenRepo.Create(tx, deviceId, familyId, HashOpaqueToken(refreshToken), time.Now().Add(s.refreshTokenDuration()))
+	accessToken, err := s.createAccess(userId, deviceId, familyId)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Refresh rotates the refresh token and returns the user and device of the session with new access and refresh tokens.
+// Using a rotated token again revokes its family, logging out both the legitimate device and whoever replayed it.
+func (s *tokenService) Refresh(refreshToken string) (uuid.UUID, uuid.UUID, string, string, error) {
+	stored := s.refreshTokenRepo.GetByHash(HashOpaqueToken(refreshToken))
+	if stored == nil || stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return uuid.Nil, uuid.Nil, "", "", errors.New("invalid refresh token")
+	}
+	if stored.UsedAt != nil {
+		s.refreshTokenRepo.RevokeFamily(stored.FamilyID)
+		return uuid.Nil, uuid.Nil, "", "", ErrRefreshTokenReused
+	}
+
+	next := NewOpaqueToken()
+	if s.refreshTokenRepo.Rotate(stored.ID, HashOpaqueToken(next), time.Now().Add(s.refreshTokenDuration())) == 0 {
+		// used concurrently by another request
+		s.refreshTokenRepo.RevokeFamily(stored.FamilyID)
+		return uuid.Nil, uuid.Nil, "", "", ErrRefreshTokenReused
+	}
+
+	device := s.deviceRepo.GetOne(stored.DeviceID)
+	if device.ID == uuid.Nil {
+		return uuid.Nil, uuid.Nil, "", "", errors.New("device not found")
+	}
+	accessToken, err := s.createAccess(device.UserID, device.ID, stored.FamilyID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", "", err
+	}
+	return device.UserID, device.ID, accessToken, next, nil
+}
+
+// access tokens last 15 minutes unless accessTokenMinutes is set, apps renew them with their refresh token
+func (s *tokenService) accessTokenDuration() time.Duration {
+	if s.cfg.AccessTokenMinutes > 0 {
+		return time.Duration(s.cfg.AccessTokenMinutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+func (s *tokenService) refreshTokenDuration() time.Duration {
+	if s.cfg.RefreshTokenDays > 0 {
+		return time.Duration(s.cfg.RefreshTokenDays) * 24 * time.Hour
+	}
+	return 30 * 24 * time.Hour
+}
+
+func (s *tokenService) createAccess(userId, deviceId, familyId uuid.UUID) (string, error) {
 	claims := jwt.MapClaims{
 		"userId":   userId,
 		"deviceId": deviceId,
 		"iat":      time.Now().Unix(),
-		"exp":      time.Now().Add(7 * 24 * time.Hour).Unix(),
+		"exp":      time.Now().Add(s.accessTokenDuration()).Unix(),
 		"iss":      "mahaam-api",
 	}
+	if familyId != uuid.Nil {
+		claims["familyId"] = familyId
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.cfg.TokenSecretKey))
+	key := s.keys.active
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	signedToken, err := token.SignedString(key.private)
 	if err != nil {
 		return "", errors.New("failed to sign token: " + err.Error())
 	}
@@ -98,12 +196,7 @@ func (s *tokenService) Create(userId, deviceId uuid.UUID) (string, error) {
 }
 
 func (s *tokenService) validateJwt(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(s.cfg.TokenSecretKey), nil
-	})
+	token, err := jwt.Parse(tokenString, s.keys.verificationKey)
 	if err != nil {
 		return nil, errors.New("invalid token: " + err.Error())
 	}
@@ -115,3 +208,8 @@ func (s *tokenService) validateJwt(tokenString string) (jwt.MapClaims, error) {
 
 	return claims, nil
 }
+
+// JWKS returns the public keys that verify access tokens, retired and symmetric keys are left out
+func (s *tokenService) JWKS() JWKSet {
+	return s.keys.jwks
+}
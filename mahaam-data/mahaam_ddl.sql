@@ -1,13 +1,29 @@
 DROP TABLE IF EXISTS app.tasks;
 DROP TABLE IF EXISTS app.plan_members;
+DROP TABLE IF EXISTS app.plan_pins;
+DROP TABLE IF EXISTS app.calendar_feeds;
+DROP TABLE IF EXISTS app.takeout_jobs;
+DROP TABLE IF EXISTS app.otps;
+DROP TABLE IF EXISTS app.auth_attempts;
+DROP TABLE IF EXISTS app.rate_limits;
+DROP TABLE IF EXISTS app.refresh_tokens;
+DROP TABLE IF EXISTS app.user_preferences;
+DROP TABLE IF EXISTS app.oidc_logins;
+DROP TABLE IF EXISTS app.user_identities;
+DROP TABLE IF EXISTS app.access_tokens;
+DROP TABLE IF EXISTS app.contact_group_members;
+DROP TABLE IF EXISTS app.contact_groups;
+DROP TABLE IF EXISTS app.blocked_users;
 DROP TABLE IF EXISTS app.suggested_emails;
 DROP TABLE IF EXISTS app.devices;
 DROP TABLE IF EXISTS app.plans;
+DROP TABLE IF EXISTS app.plan_categories;
 DROP TABLE IF EXISTS app.users;
 --
 DROP TABLE IF EXISTS monitor.health;
 DROP TABLE IF EXISTS monitor.log;
 DROP TABLE IF EXISTS monitor.traffic;
+DROP TABLE IF EXISTS monitor.cleanup_runs;
 --
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 --
@@ -16,11 +32,13 @@ CREATE TABLE app.users (
 	id uuid NOT NULL DEFAULT uuid_generate_v4 (),
 	email varchar(255) NULL,
 	name varchar(50) NULL,
+	deletes_at timestamptz NULL,
 	created_at timestamptz NOT NULL,
 	updated_at timestamptz NULL,
 	CONSTRAINT users_pkey PRIMARY KEY (id)
 );
 CREATE UNIQUE INDEX users_unique_index_email ON app.users (email);
+CREATE INDEX users_index_deletes_at ON app.users (deletes_at) WHERE deletes_at IS NOT NULL;
 --
 
 CREATE TABLE app.devices (
@@ -29,6 +47,12 @@ CREATE TABLE app.devices (
 	platform TEXT NULL,
 	fingerprint varchar(255) NOT NULL,
 	info varchar(255) NOT NULL,
+	name varchar(100) NULL,
+	last_seen_at timestamptz NULL,
+	last_ip varchar(45) NULL,
+	attestation_provider varchar(20) NULL,
+	attestation_verdict varchar(20) NULL,
+	attested_at timestamptz NULL,
 	created_at timestamptz NOT NULL,
 	updated_at timestamptz NULL,
 	CONSTRAINT devices_pkey PRIMARY KEY (id),
@@ -36,10 +60,29 @@ CREATE TABLE app.devices (
 );
 --
 
+CREATE TABLE app.refresh_tokens (
+	id uuid NOT NULL,
+	device_id uuid NOT NULL,
+	family_id uuid NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL,
+	used_at timestamptz NULL,
+	revoked_at timestamptz NULL,
+	CONSTRAINT refresh_tokens_pkey PRIMARY KEY (id),
+	CONSTRAINT refresh_tokens_device_id_fkey FOREIGN KEY (device_id) REFERENCES app.devices (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX refresh_tokens_unique_index_token_hash ON app.refresh_tokens (token_hash);
+CREATE INDEX refresh_tokens_index_device_id ON app.refresh_tokens (device_id);
+CREATE INDEX refresh_tokens_index_family_id ON app.refresh_tokens (family_id);
+--
+
 CREATE TABLE app.suggested_emails (
 	id uuid NOT NULL,
 	user_id uuid NOT NULL,
 	email varchar(255) NULL,
+	share_count int4 NOT NULL DEFAULT 1,
+	last_shared_at timestamptz NULL,
 	created_at timestamptz NOT NULL,
 	CONSTRAINT suggested_emails_pkey PRIMARY KEY (id),
 	CONSTRAINT suggested_emails_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
@@ -59,20 +102,194 @@ CREATE TABLE app.plans (
 	sort_order int4 NOT NULL,
 	created_at timestamptz NOT NULL,
 	updated_at timestamptz NULL,
+	search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', coalesce(title, ''))) STORED,
 	CONSTRAINT plans_pk PRIMARY KEY (id),
 	CONSTRAINT plans_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
 );
 CREATE INDEX plans_index_type ON app.plans (type);
+CREATE INDEX plans_index_search_vector ON app.plans USING GIN (search_vector);
+--
+
+CREATE TABLE app.plan_categories (
+	id uuid NOT NULL DEFAULT uuid_generate_v4 (),
+	user_id uuid NOT NULL,
+	name varchar(50) NOT NULL,
+	sort_order int4 NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NULL,
+	CONSTRAINT plan_categories_pkey PRIMARY KEY (id),
+	CONSTRAINT plan_categories_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX plan_categories_unique_index_user_id_name ON app.plan_categories (user_id, lower(name));
 --
 
 CREATE TABLE app.plan_members (
 	plan_id uuid NOT NULL,
 	user_id uuid NOT NULL,
+	type VARCHAR(50) NOT NULL DEFAULT 'Main',
+	sort_order int4 NOT NULL DEFAULT 0,
 	created_at timestamptz NOT NULL,
 	CONSTRAINT plan_members_pkey PRIMARY KEY (plan_id, user_id),
 	CONSTRAINT plan_members_plan_id_fkey FOREIGN KEY (plan_id) REFERENCES app.plans (id) ON DELETE CASCADE,
 	CONSTRAINT plan_members_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
 );
+CREATE INDEX plan_members_index_user_id_type ON app.plan_members (user_id, type);
+--
+
+CREATE TABLE app.plan_pins (
+	user_id uuid NOT NULL,
+	plan_id uuid NOT NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT plan_pins_pkey PRIMARY KEY (user_id, plan_id),
+	CONSTRAINT plan_pins_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE,
+	CONSTRAINT plan_pins_plan_id_fkey FOREIGN KEY (plan_id) REFERENCES app.plans (id) ON DELETE CASCADE
+);
+--
+
+CREATE TABLE app.calendar_feeds (
+	user_id uuid NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	created_at timestamptz NOT NULL,
+	last_accessed_at timestamptz NULL,
+	CONSTRAINT calendar_feeds_pkey PRIMARY KEY (user_id),
+	CONSTRAINT calendar_feeds_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX calendar_feeds_unique_index_token_hash ON app.calendar_feeds (token_hash);
+--
+
+CREATE TABLE app.access_tokens (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	name varchar(100) NOT NULL,
+	scopes varchar(10)[] NOT NULL,
+	token_hash varchar(64) NOT NULL,
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NULL,
+	last_used_at timestamptz NULL,
+	CONSTRAINT access_tokens_pkey PRIMARY KEY (id),
+	CONSTRAINT access_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX access_tokens_unique_index_token_hash ON app.access_tokens (token_hash);
+CREATE INDEX access_tokens_index_user_id ON app.access_tokens (user_id);
+--
+
+CREATE TABLE app.oidc_logins (
+	state_hash varchar(64) NOT NULL,
+	provider varchar(50) NOT NULL,
+	device_id uuid NOT NULL,
+	code_verifier varchar(64) NOT NULL,
+	nonce varchar(64) NOT NULL,
+	created_at timestamptz NOT NULL,
+	expires_at timestamptz NOT NULL,
+	CONSTRAINT oidc_logins_pkey PRIMARY KEY (state_hash),
+	CONSTRAINT oidc_logins_device_id_fkey FOREIGN KEY (device_id) REFERENCES app.devices (id) ON DELETE CASCADE
+);
+CREATE INDEX oidc_logins_index_device_id ON app.oidc_logins (device_id);
+--
+
+CREATE TABLE app.user_identities (
+	provider varchar(50) NOT NULL,
+	subject varchar(255) NOT NULL,
+	user_id uuid NOT NULL,
+	email varchar(255) NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT user_identities_pkey PRIMARY KEY (provider, subject),
+	CONSTRAINT user_identities_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE INDEX user_identities_index_user_id ON app.user_identities (user_id);
+--
+
+CREATE TABLE app.user_preferences (
+	user_id uuid NOT NULL,
+	timezone varchar(64) NOT NULL,
+	locale varchar(35) NOT NULL,
+	week_start varchar(10) NOT NULL,
+	default_plan_type varchar(50) NOT NULL,
+	notify_plan_shared bool NOT NULL,
+	notify_takeout_ready bool NOT NULL,
+	theme varchar(10) NOT NULL,
+	updated_at timestamptz NOT NULL,
+	CONSTRAINT user_preferences_pkey PRIMARY KEY (user_id),
+	CONSTRAINT user_preferences_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+--
+
+CREATE TABLE app.contact_groups (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	name varchar(100) NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NULL,
+	CONSTRAINT contact_groups_pkey PRIMARY KEY (id),
+	CONSTRAINT contact_groups_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX contact_groups_unique_index_user_id_name ON app.contact_groups (user_id, name);
+--
+
+CREATE TABLE app.contact_group_members (
+	group_id uuid NOT NULL,
+	contact_id uuid NOT NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT contact_group_members_pkey PRIMARY KEY (group_id, contact_id),
+	CONSTRAINT contact_group_members_group_id_fkey FOREIGN KEY (group_id) REFERENCES app.contact_groups (id) ON DELETE CASCADE,
+	CONSTRAINT contact_group_members_contact_id_fkey FOREIGN KEY (contact_id) REFERENCES app.suggested_emails (id) ON DELETE CASCADE
+);
+--
+
+CREATE TABLE app.blocked_users (
+	user_id uuid NOT NULL,
+	blocked_user_id uuid NOT NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT blocked_users_pkey PRIMARY KEY (user_id, blocked_user_id),
+	CONSTRAINT blocked_users_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE,
+	CONSTRAINT blocked_users_blocked_user_id_fkey FOREIGN KEY (blocked_user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+--
+
+CREATE TABLE app.takeout_jobs (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	status varchar(20) NOT NULL,
+	error text NULL,
+	created_at timestamptz NOT NULL,
+	started_at timestamptz NULL,
+	heartbeat_at timestamptz NULL,
+	completed_at timestamptz NULL,
+	expires_at timestamptz NULL,
+	CONSTRAINT takeout_jobs_pkey PRIMARY KEY (id),
+	CONSTRAINT takeout_jobs_user_id_fkey FOREIGN KEY (user_id) REFERENCES app.users (id) ON DELETE CASCADE
+);
+CREATE INDEX takeout_jobs_index_status ON app.takeout_jobs (status);
+--
+
+CREATE TABLE app.otps (
+	sid uuid NOT NULL,
+	email varchar(255) NOT NULL,
+	code_hash varchar(64) NOT NULL,
+	attempts int4 NOT NULL DEFAULT 0,
+	expires_at timestamptz NOT NULL,
+	approved_at timestamptz NULL,
+	created_at timestamptz NOT NULL,
+	CONSTRAINT otps_pkey PRIMARY KEY (sid)
+);
+CREATE INDEX otps_index_email ON app.otps (email);
+--
+
+CREATE TABLE app.auth_attempts (
+	key varchar(300) NOT NULL,
+	attempts int4 NOT NULL,
+	last_attempt_at timestamptz NOT NULL,
+	locked_until timestamptz NULL,
+	CONSTRAINT auth_attempts_pkey PRIMARY KEY (key)
+);
+--
+
+CREATE TABLE app.rate_limits (
+	key varchar(300) NOT NULL,
+	hits int4 NOT NULL,
+	window_started_at timestamptz NOT NULL,
+	CONSTRAINT rate_limits_pkey PRIMARY KEY (key)
+);
 --
 
 CREATE TABLE app.tasks (
@@ -80,12 +297,15 @@ CREATE TABLE app.tasks (
 	plan_id uuid NOT NULL,
 	title varchar(255) NOT NULL,
 	done bool NOT NULL,
+	due date NULL,
 	sort_order int4 NOT NULL,
 	created_at timestamptz NOT NULL,
 	updated_at timestamptz NULL,
+	search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', title)) STORED,
 	CONSTRAINT tasks_pkey PRIMARY KEY (id),
 	CONSTRAINT tasks_fkey FOREIGN KEY (plan_id) REFERENCES app.plans (id) ON DELETE CASCADE
 );
+CREATE INDEX tasks_index_search_vector ON app.tasks USING GIN (search_vector);
 --
 
 CREATE TABLE monitor.logs (
@@ -125,4 +345,17 @@ CREATE TABLE monitor.traffic (
 	response text NULL,
 	created_at timestamptz NOT NULL,
 	CONSTRAINT traffic_pkey PRIMARY KEY (id)
-);
\ No newline at end of file
+);
+--
+
+CREATE TABLE monitor.cleanup_runs (
+	id uuid NOT NULL,
+	health_id uuid NOT NULL,
+	dry_run bool NOT NULL,
+	inactive_days int4 NOT NULL,
+	candidates int8 NOT NULL,
+	deleted int8 NOT NULL,
+	started_at timestamptz NOT NULL,
+	finished_at timestamptz NOT NULL,
+	CONSTRAINT cleanup_runs_pkey PRIMARY KEY (id)
+);
@@ -0,0 +1,16 @@
+-- Adds the member's own type and order to plan_members of an existing database.
+-- Shared plans go to the member's Main list, after their owned Main plans, oldest share first.
+ALTER TABLE app.plan_members ADD COLUMN IF NOT EXISTS type VARCHAR(50) NOT NULL DEFAULT 'Main';
+ALTER TABLE app.plan_members ADD COLUMN IF NOT EXISTS sort_order int4 NOT NULL DEFAULT 0;
+
+UPDATE app.plan_members cm
+SET sort_order = o.sort_order
+FROM (
+	SELECT plan_id, user_id,
+		(SELECT COUNT(1) FROM app.plans p WHERE p.user_id = m.user_id AND p.type = 'Main')
+		+ ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at, plan_id) - 1 AS sort_order
+	FROM app.plan_members m
+) o
+WHERE cm.plan_id = o.plan_id AND cm.user_id = o.user_id;
+
+CREATE INDEX IF NOT EXISTS plan_members_index_user_id_type ON app.plan_members (user_id, type);
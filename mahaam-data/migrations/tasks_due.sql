@@ -0,0 +1,2 @@
+-- Adds the task due date to tasks of an existing database, tasks without a due date are not exported as VTODOs.
+ALTER TABLE app.tasks ADD COLUMN IF NOT EXISTS due date NULL;